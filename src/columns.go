@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// patronColumn describes one Patron field and the CSV headers it can be read from.
+// Patreon has renamed several headers over the years, so each column lists every
+// header name we know about. The first alias is the current Patreon name.
+type patronColumn struct {
+	Key      string
+	Aliases  []string
+	Required bool
+	Field    func(p *Patron) *string
+}
+
+var patronColumns = []patronColumn{
+	{Key: "name", Aliases: []string{"Name", "Patron Name", "Full Name"}, Required: true, Field: func(p *Patron) *string { return &p.Name }},
+	{Key: "email", Aliases: []string{"Email", "Email Address"}, Field: func(p *Patron) *string { return &p.Email }},
+	{Key: "discord", Aliases: []string{"Discord", "Discord Username"}, Field: func(p *Patron) *string { return &p.Discord }},
+	{Key: "patron_status", Aliases: []string{"Patron Status", "Status"}, Field: func(p *Patron) *string { return &p.PatronStatus }},
+	{Key: "follows_you", Aliases: []string{"Follows You", "Follower"}, Field: func(p *Patron) *string { return &p.FollowsYou }},
+	{Key: "free_member", Aliases: []string{"Free Member"}, Field: func(p *Patron) *string { return &p.FreeMember }},
+	{Key: "free_trial", Aliases: []string{"Free Trial"}, Field: func(p *Patron) *string { return &p.FreeTrial }},
+	{Key: "lifetime_amount", Aliases: []string{"Lifetime Amount", "Lifetime $", "Lifetime"}, Field: func(p *Patron) *string { return &p.LifetimeAmount }},
+	{Key: "pledge_amount", Aliases: []string{"Pledge Amount", "Pledge $", "Pledge"}, Field: func(p *Patron) *string { return &p.PledgeAmount }},
+	{Key: "charge_frequency", Aliases: []string{"Charge Frequency", "Billing Frequency"}, Field: func(p *Patron) *string { return &p.ChargeFrequency }},
+	{Key: "tier", Aliases: []string{"Tier", "Reward", "Reward Title"}, Required: true, Field: func(p *Patron) *string { return &p.Tier }},
+	{Key: "addressee", Aliases: []string{"Addressee", "Address Name"}, Field: func(p *Patron) *string { return &p.Addressee }},
+	{Key: "street", Aliases: []string{"Street", "Address Line"}, Field: func(p *Patron) *string { return &p.Street }},
+	{Key: "city", Aliases: []string{"City"}, Field: func(p *Patron) *string { return &p.City }},
+	{Key: "state", Aliases: []string{"State", "State/Province"}, Field: func(p *Patron) *string { return &p.State }},
+	{Key: "zip", Aliases: []string{"Zip", "Postal Code", "Zip/Postal Code"}, Field: func(p *Patron) *string { return &p.Zip }},
+	{Key: "country", Aliases: []string{"Country", "Country Code"}, Field: func(p *Patron) *string { return &p.Country }},
+	{Key: "phone", Aliases: []string{"Phone", "Phone Number"}, Field: func(p *Patron) *string { return &p.Phone }},
+	{Key: "patronage_since_date", Aliases: []string{"Patronage Since Date", "Patron Since", "Pledge Start Date"}, Field: func(p *Patron) *string { return &p.PatronageSinceDate }},
+	{Key: "last_charge_date", Aliases: []string{"Last Charge Date", "Last Charged"}, Field: func(p *Patron) *string { return &p.LastChargeDate }},
+	{Key: "last_charge_status", Aliases: []string{"Last Charge Status", "Charge Status"}, Required: true, Field: func(p *Patron) *string { return &p.LastChargeStatus }},
+	{Key: "additional_details", Aliases: []string{"Additional Details", "Note", "Notes"}, Field: func(p *Patron) *string { return &p.AdditionalDetails }},
+	{Key: "user_id", Aliases: []string{"User ID", "Patron ID", "Patreon User ID"}, Field: func(p *Patron) *string { return &p.UserID }},
+	{Key: "last_updated", Aliases: []string{"Last Updated", "Last Modified"}, Field: func(p *Patron) *string { return &p.LastUpdated }},
+	{Key: "currency", Aliases: []string{"Currency", "Currency Code"}, Field: func(p *Patron) *string { return &p.Currency }},
+	{Key: "max_posts", Aliases: []string{"Max Posts", "Max Amount"}, Field: func(p *Patron) *string { return &p.MaxPosts }},
+	{Key: "access_expiration", Aliases: []string{"Access Expiration", "Access Expiration Date", "Access Expires"}, Field: func(p *Patron) *string { return &p.AccessExpiration }},
+	{Key: "next_charge_date", Aliases: []string{"Next Charge Date", "Next Charge"}, Field: func(p *Patron) *string { return &p.NextChargeDate }},
+	{Key: "full_country_name", Aliases: []string{"Full country name", "Country Name"}, Field: func(p *Patron) *string { return &p.FullCountryName }},
+	{Key: "subscription_source", Aliases: []string{"Subscription Source", "Source"}, Field: func(p *Patron) *string { return &p.SubscriptionSource }},
+}
+
+// columnIndex maps each patronColumn (by position in patronColumns) to its index
+// in the CSV header, or -1 when the column is not present in this export.
+type columnIndex []int
+
+// normalizeHeader folds a header so "Patron Status", "PatronStatus" and
+// "patron_status" all compare equal.
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimPrefix(h, "\ufeff")) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// mapColumns binds every known Patron field to its position in the header row.
+// It returns an error naming every required column that could not be found.
+func mapColumns(header []string) (columnIndex, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		n := normalizeHeader(h)
+		if _, seen := positions[n]; !seen {
+			positions[n] = i
+		}
+	}
+
+	index := make(columnIndex, len(patronColumns))
+	var missing []string
+	for c, col := range patronColumns {
+		index[c] = -1
+		for _, alias := range col.Aliases {
+			if pos, ok := positions[normalizeHeader(alias)]; ok {
+				index[c] = pos
+				break
+			}
+		}
+		if index[c] == -1 && col.Required {
+			missing = append(missing, fmt.Sprintf("'%s'", col.Aliases[0]))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV is missing required column(s): %s", strings.Join(missing, ", "))
+	}
+	return index, nil
+}
+
+// bind fills a Patron from a single CSV record. It reports false when the record
+// is too short to contain every required column that the header declared; the
+// returned Patron then holds whatever fields the record did contain.
+func (index columnIndex) bind(record []string) (Patron, bool) {
+	var patron Patron
+	ok := true
+	for c, col := range patronColumns {
+		pos := index[c]
+		if pos < 0 {
+			continue
+		}
+		if pos >= len(record) {
+			if col.Required {
+				ok = false
+			}
+			continue
+		}
+		*col.Field(&patron) = record[pos]
+	}
+	return patron, ok
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHeader(t *testing.T) {
+	for _, h := range []string{"Patron Status", "PatronStatus", "patron_status", "\ufeffPatron Status"} {
+		if got := normalizeHeader(h); got != "patronstatus" {
+			t.Errorf("normalizeHeader(%q) = %q, want %q", h, got, "patronstatus")
+		}
+	}
+}
+
+func TestMapColumns_Aliases(t *testing.T) {
+	header := []string{"Reward", "Patron Name", "Charge Status", "Pledge $"}
+	index, err := mapColumns(header)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	patron, ok := index.bind([]string{"Gold", "Alice", "Paid", "5.00"})
+	if !ok {
+		t.Fatal("expected row to bind")
+	}
+	if patron.Tier != "Gold" || patron.Name != "Alice" || patron.LastChargeStatus != "Paid" || patron.PledgeAmount != "5.00" {
+		t.Errorf("unexpected patron: %+v", patron)
+	}
+}
+
+func TestMapColumns_ReportsAllMissing(t *testing.T) {
+	_, err := mapColumns([]string{"Email"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"'Name'", "'Tier'", "'Last Charge Status'"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// datedRate is one exchange rate from the RATES_FILE.
+type datedRate struct {
+	Date time.Time
+	Rate *big.Rat
+}
+
+// rateTable holds the exchange rates of a RATES_FILE, by currency pair. Each
+// pair keeps its rates oldest first.
+type rateTable struct {
+	Path  string
+	rates map[[2]string][]datedRate
+}
+
+// loadRateTable reads a CSV file of "date,from,to,rate" lines, where one unit
+// of from is worth rate units of to on that date. A header line and lines
+// starting with # are skipped.
+func loadRateTable(path string) (*rateTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening rates file: %v", err)
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading rates file: %v", err)
+	}
+	t := &rateTable{Path: path, rates: make(map[[2]string][]datedRate)}
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		date, err := parsePatreonDate(record[0])
+		if err != nil || date.IsZero() {
+			return nil, fmt.Errorf("%s line %d: invalid date %q", path, i+1, record[0])
+		}
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(record[3]))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("%s line %d: invalid rate %q", path, i+1, record[3])
+		}
+		pair := [2]string{strings.ToUpper(strings.TrimSpace(record[1])), strings.ToUpper(strings.TrimSpace(record[2]))}
+		t.rates[pair] = append(t.rates[pair], datedRate{Date: date, Rate: rate})
+	}
+	for _, rates := range t.rates {
+		sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	}
+	return t, nil
+}
+
+// pairRate is the latest rate for exactly this pair on or before on.
+func (t *rateTable) pairRate(from, to string, on time.Time) (*big.Rat, bool) {
+	var found *big.Rat
+	for _, r := range t.rates[[2]string{from, to}] {
+		if r.Date.After(on) {
+			break
+		}
+		found = r.Rate
+	}
+	return found, found != nil
+}
+
+// directRate uses the pair as listed or the inverse of the opposite pair.
+func (t *rateTable) directRate(from, to string, on time.Time) (*big.Rat, bool) {
+	if r, ok := t.pairRate(from, to, on); ok {
+		return r, true
+	}
+	if r, ok := t.pairRate(to, from, on); ok {
+		return new(big.Rat).Inv(r), true
+	}
+	return nil, false
+}
+
+// rate is the value of one unit of from in to on the given date, using the
+// newest rate dated on or before it. When the table has no rate between the
+// two it goes through a third currency, so a table of rates against one base
+// currency (such as the ECB's euro rates) converts between any two of them.
+func (t *rateTable) rate(from, to string, on time.Time) (*big.Rat, bool) {
+	if from == to {
+		return big.NewRat(1, 1), true
+	}
+	if r, ok := t.directRate(from, to, on); ok {
+		return r, true
+	}
+	via := make(map[string]bool)
+	for pair := range t.rates {
+		via[pair[0]] = true
+		via[pair[1]] = true
+	}
+	currencies := make([]string, 0, len(via))
+	for c := range via {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	for _, c := range currencies {
+		first, ok := t.directRate(from, c, on)
+		if !ok {
+			continue
+		}
+		if second, ok := t.directRate(c, to, on); ok {
+			return new(big.Rat).Mul(first, second), true
+		}
+	}
+	return nil, false
+}
+
+// currencyConverter converts amounts to the REPORTING_CURRENCY at the rates
+// of one date. Currencies it has no rate for are counted in Missing.
+type currencyConverter struct {
+	To      string
+	On      time.Time
+	table   *rateTable
+	Missing map[string]int
+}
+
+func newCurrencyConverter(settings Settings, on time.Time) (*currencyConverter, error) {
+	if settings.ReportingCurrency == "" {
+		return nil, nil
+	}
+	table, err := loadRateTable(settings.RatesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &currencyConverter{To: settings.ReportingCurrency, On: on, table: table, Missing: make(map[string]int)}, nil
+}
+
+// convert returns m in the reporting currency, rounded half away from zero to
+// its minor unit. Without a rate it returns m unchanged and false.
+func (c *currencyConverter) convert(m Money) (Money, bool) {
+	if c == nil || m.Currency == c.To {
+		return m, true
+	}
+	rate, ok := c.table.rate(m.Currency, c.To, c.On)
+	if !ok {
+		c.Missing[m.Currency]++
+		return m, false
+	}
+	// minor units of To = minor units of From * rate * 10^(expTo - expFrom)
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor), rate)
+	shift := currencyExponent(c.To) - currencyExponent(m.Currency)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		v.Mul(v, scale)
+	} else {
+		v.Quo(v, scale)
+	}
+	return Money{Minor: roundRat(v), Currency: c.To}, true
+}
+
+// warnings describe the currencies that could not be converted.
+func (c *currencyConverter) warnings() []string {
+	if c == nil {
+		return nil
+	}
+	currencies := make([]string, 0, len(c.Missing))
+	for currency := range c.Missing {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	var warnings []string
+	for _, currency := range currencies {
+		name := currency
+		if name == "" {
+			name = "(no currency)"
+		}
+		warnings = append(warnings, fmt.Sprintf("no %s to %s rate on or before %s in %s; %d amount(s) left in %s",
+			name, c.To, c.On.Format("2006-01-02"), c.table.Path, c.Missing[currency], name))
+	}
+	return warnings
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// roundRat rounds v to the nearest integer, halves away from zero.
+func roundRat(v *big.Rat) int64 {
+	num := new(big.Int).Abs(v.Num())
+	q, r := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+	if r.Mul(r, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
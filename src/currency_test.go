@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRatesFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.csv")
+	rates := `date,from,to,rate
+# euro reference rates
+2024-01-02,EUR,USD,1.10
+2024-05-01,EUR,USD,1.07
+2024-05-01,EUR,JPY,166.5
+2024-05-01,KWD,USD,3.25
+`
+	if err := os.WriteFile(path, []byte(rates), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	settings := defaultSettings()
+	settings.ReportingCurrency = "USD"
+	settings.RatesFile = writeRatesFile(t)
+	may := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	conv, err := newCurrencyConverter(settings, may)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		in   Money
+		want string
+	}{
+		{Money{Minor: 1000, Currency: "USD"}, "10.00 USD"},
+		{Money{Minor: 1000, Currency: "EUR"}, "10.70 USD"},
+		// 1000 JPY = 1000 / 166.5 EUR = 6.006 EUR = 6.4264 USD
+		{Money{Minor: 1000, Currency: "JPY"}, "6.43 USD"},
+		// KWD has three decimals: 1.500 KWD = 4.875 USD, rounded up.
+		{Money{Minor: 1500, Currency: "KWD"}, "4.88 USD"},
+	} {
+		got, ok := conv.convert(tc.in)
+		if !ok || got.String() != tc.want {
+			t.Errorf("convert(%s) = %s, %v; want %s", tc.in, got, ok, tc.want)
+		}
+	}
+
+	if got, ok := conv.convert(Money{Minor: 500, Currency: "GBP"}); ok || got.Currency != "GBP" {
+		t.Errorf("convert(GBP) = %s, %v; want it unchanged", got, ok)
+	}
+	warnings := conv.warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no GBP to USD rate on or before 2024-05-15") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// Older runs use the rates of their own date.
+	january, err := newCurrencyConverter(settings, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := january.convert(Money{Minor: 1000, Currency: "EUR"}); got.String() != "11.00 USD" {
+		t.Errorf("January EUR = %s, want 11.00 USD", got)
+	}
+	if _, ok := january.convert(Money{Minor: 1000, Currency: "JPY"}); ok {
+		t.Error("JPY converted with a rate from after the reference date")
+	}
+}
+
+func TestBuildStatsConverted(t *testing.T) {
+	settings := defaultSettings()
+	settings.ReportingCurrency = "USD"
+	settings.RatesFile = writeRatesFile(t)
+	conv, err := newCurrencyConverter(settings, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gold := []Patron{
+		{Name: "Alice", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+		{Name: "Bob", Tier: "Gold", PledgeAmount: "10.00", Currency: "EUR"},
+		{Name: "Carol", Tier: "Gold", PledgeAmount: "5.00", Currency: "GBP"},
+	}
+	r := buildStats(gold, []TierGroup{{Name: "Gold", Patrons: gold}}, runSummary{}, 10, conv)
+	if got := r.MRR.String(); got != "5.00 GBP, 20.70 USD" {
+		t.Errorf("MRR = %s", got)
+	}
+	if got := r.AveragePledge.String(); got != "5.00 GBP, 10.35 USD" {
+		t.Errorf("average pledge = %s", got)
+	}
+}
+
+func TestReportingCurrencyValidation(t *testing.T) {
+	settings := defaultSettings()
+	settings.ReportingCurrency = "USD"
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "RATES_FILE") {
+		t.Errorf("Validate() without RATES_FILE = %v", err)
+	}
+	settings.RatesFile = writeRatesFile(t)
+	if err := settings.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
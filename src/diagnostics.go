@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Reasons a row can appear in the import diagnostics report.
+const (
+	ReasonMalformed        = "malformed row"
+	ReasonFreeTier         = "free tier"
+	ReasonNoTier           = "no tier"
+	ReasonUnpaid           = "unpaid"
+	ReasonExpiredAccess    = "expired access"
+	ReasonGracePeriod      = "grace period"
+	ReasonIncludeRule      = "include rule not met"
+	ReasonExcludeRule      = "exclude rule matched"
+	ReasonUnparseableDate  = "unparseable date"
+	ReasonUnparseableValue = "unparseable value"
+)
+
+// What happened to a row that appears in the diagnostics report.
+const (
+	ActionDropped  = "dropped"  // the row could not be read at all
+	ActionExcluded = "excluded" // the patron was read but filtered out
+	ActionWarning  = "warning"  // the patron was kept but something looked wrong
+)
+
+// Diagnostic is one entry in the import diagnostics report.
+type Diagnostic struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name"`
+	UserID string `json:"user_id"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Diagnostics collects why rows were dropped or excluded during an import.
+// A nil *Diagnostics is valid and discards everything added to it.
+type Diagnostics struct {
+	ReferenceDate time.Time
+	Entries       []Diagnostic
+}
+
+func (d *Diagnostics) add(p Patron, action, reason, detail string) {
+	if d == nil {
+		return
+	}
+	d.Entries = append(d.Entries, Diagnostic{
+		Line:   p.Line,
+		Name:   p.Name,
+		UserID: p.UserID,
+		Action: action,
+		Reason: reason,
+		Detail: detail,
+	})
+}
+
+// addParseErrors records a warning for every field of p that failed to parse.
+func (d *Diagnostics) addParseErrors(p Patron) {
+	for _, fe := range p.ParseErrors {
+		reason := ReasonUnparseableValue
+		if isDateColumn(fe.Column) {
+			reason = ReasonUnparseableDate
+		}
+		d.add(p, ActionWarning, reason, fe.Error())
+	}
+}
+
+func isDateColumn(column string) bool {
+	switch column {
+	case "Patronage Since Date", "Last Charge Date", "Next Charge Date", "Access Expiration", "Last Updated":
+		return true
+	}
+	return false
+}
+
+// Count returns how many entries have the given reason.
+func (d *Diagnostics) Count(reason string) int {
+	if d == nil {
+		return 0
+	}
+	n := 0
+	for _, e := range d.Entries {
+		if e.Reason == reason {
+			n++
+		}
+	}
+	return n
+}
+
+// writeDiagnostics writes the report as CSV or JSON depending on format.
+func writeDiagnostics(path string, format string, d *Diagnostics) error {
+	switch format {
+	case "json":
+		return writeDiagnosticsJSON(path, d)
+	case "csv":
+		return writeDiagnosticsCSV(path, d)
+	}
+	return fmt.Errorf("unknown diagnostics format %q", format)
+}
+
+func writeDiagnosticsCSV(path string, d *Diagnostics) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"Line", "Name", "User ID", "Action", "Reason", "Detail"}
+	if !d.ReferenceDate.IsZero() {
+		writeReferenceRow(w, d.ReferenceDate.Format(time.RFC3339), len(header))
+	}
+	w.Write(header)
+	for _, e := range d.Entries {
+		w.Write([]string{strconv.Itoa(e.Line), e.Name, e.UserID, e.Action, e.Reason, e.Detail})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeReferenceRow starts import_report.csv with a "# reference date: ..."
+// row, so the date stays with the report wherever it is copied. The row is padded to
+// the header's columns for readers that expect every row to be as wide, and
+// readers that treat # as a comment skip it.
+func writeReferenceRow(w *csv.Writer, referenceDate string, columns int) {
+	if referenceDate == "" {
+		return
+	}
+	row := make([]string, columns)
+	row[0] = "# reference date: " + referenceDate
+	w.Write(row)
+}
+
+func writeDiagnosticsJSON(path string, d *Diagnostics) error {
+	report := struct {
+		ReferenceDate time.Time    `json:"reference_date"`
+		Entries       []Diagnostic `json:"entries"`
+	}{d.ReferenceDate, d.Entries}
+	if report.Entries == nil {
+		report.Entries = []Diagnostic{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
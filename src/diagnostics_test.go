@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnostics_ParseAndFilter(t *testing.T) {
+	records := [][]string{
+		{"Name", "User ID", "Tier", "Last Charge Status", "Access Expiration"},
+		{"Alice", "1", "Gold", "Paid", ""},
+		{"Bob", "2", "Free", "Paid", ""},
+		{"Carol", "3", "Gold", "Declined", ""},
+		{"Dave", "4", "Gold", "Paid", "2023-01-01 00:00:00"},
+		{"Erin", "5", "Gold", "Paid", "soon"},
+		{"Frank", "6"},
+	}
+	diag := &Diagnostics{}
+	patrons, _, err := parsePatrons(records, diag)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filtered, _, _ := filterPatrons(patrons, defaultRules(), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 0, diag)
+	if len(filtered) != 2 {
+		t.Errorf("expected Alice and Erin to be kept, got %v", filtered)
+	}
+
+	want := map[string]Diagnostic{
+		"Frank": {Line: 7, UserID: "6", Action: ActionDropped, Reason: ReasonMalformed},
+		"Bob":   {Line: 3, UserID: "2", Action: ActionExcluded, Reason: ReasonFreeTier},
+		"Carol": {Line: 4, UserID: "3", Action: ActionExcluded, Reason: ReasonUnpaid},
+		"Dave":  {Line: 5, UserID: "4", Action: ActionExcluded, Reason: ReasonExpiredAccess},
+		"Erin":  {Line: 6, UserID: "5", Action: ActionWarning, Reason: ReasonUnparseableDate},
+	}
+	if len(diag.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(want), len(diag.Entries), diag.Entries)
+	}
+	for _, e := range diag.Entries {
+		w, ok := want[e.Name]
+		if !ok {
+			t.Errorf("unexpected entry %+v", e)
+			continue
+		}
+		if e.Line != w.Line || e.UserID != w.UserID || e.Action != w.Action || e.Reason != w.Reason {
+			t.Errorf("entry for %s = %+v, want %+v", e.Name, e, w)
+		}
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	tmpDir := t.TempDir()
+	diag := &Diagnostics{ReferenceDate: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	diag.add(Patron{Name: "Bob, Jr.", UserID: "2", Line: 3}, ActionExcluded, ReasonUnpaid, `last charge status "Declined"`)
+
+	csvPath := filepath.Join(tmpDir, "import_report.csv")
+	if err := writeDiagnostics(csvPath, "csv", diag); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	records, err := readCSVFile(csvPath)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "# reference date: 2024-05-01T00:00:00Z" || len(records[0]) != len(records[1]) {
+		t.Fatalf("expected a reference date row as wide as the header, got %v", records)
+	}
+	records = records[1:]
+	if len(records) != 2 || records[1][0] != "3" || records[1][1] != "Bob, Jr." || records[1][4] != ReasonUnpaid {
+		t.Errorf("unexpected CSV report: %v", records)
+	}
+
+	jsonPath := filepath.Join(tmpDir, "import_report.json")
+	if err := writeDiagnostics(jsonPath, "json", diag); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var report struct {
+		ReferenceDate time.Time    `json:"reference_date"`
+		Entries       []Diagnostic `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !report.ReferenceDate.Equal(diag.ReferenceDate) {
+		t.Errorf("expected reference date %v, got %v", diag.ReferenceDate, report.ReferenceDate)
+	}
+	entries := report.Entries
+	if len(entries) != 1 || entries[0].UserID != "2" || !strings.Contains(entries[0].Detail, "Declined") {
+		t.Errorf("unexpected JSON report: %+v", entries)
+	}
+}
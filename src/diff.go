@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// diffPatron is a patron who joined or left between two exports.
+type diffPatron struct {
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name"`
+	Tier   string `json:"tier"`
+	Pledge string `json:"pledge"`
+}
+
+// patronChange is one field of a patron that differs between two exports.
+// Direction is "up" or "down" for tier and pledge changes.
+type patronChange struct {
+	UserID    string `json:"user_id,omitempty"`
+	Name      string `json:"name"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// rosterDiff is what changed between an older and a newer export.
+type rosterDiff struct {
+	OldFile       string         `json:"old_file"`
+	NewFile       string         `json:"new_file"`
+	OldDate       string         `json:"old_reference_date"`
+	NewDate       string         `json:"new_reference_date"`
+	Added         []diffPatron   `json:"added"`
+	Removed       []diffPatron   `json:"removed"`
+	TierChanges   []patronChange `json:"tier_changes"`
+	PledgeChanges []patronChange `json:"pledge_changes"`
+	Renames       []patronChange `json:"renames"`
+}
+
+// exportDate guesses when an export was downloaded from the newest Last
+// Updated value in it. It returns the zero time when no row has one.
+func exportDate(patrons []Patron) time.Time {
+	var latest time.Time
+	for _, p := range patrons {
+		if p.Values != nil && p.Values.LastUpdated.After(latest) {
+			latest = p.Values.LastUpdated
+		}
+	}
+	return latest
+}
+
+// loadFilteredExport reads an export and applies the same tier aliases, rules
+// and grace period as a normal run. The rules are judged as of asOf, or as of
+// exportDate when asOf is zero, so an old export is not filtered against
+// today's date. It returns the kept patrons and the date used.
+func loadFilteredExport(csvPath string, settings Settings, rules []Rule, asOf time.Time) ([]Patron, time.Time, error) {
+	records, err := readCSVFile(csvPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	patrons, _, err := parsePatrons(records, &Diagnostics{})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%s: %v", csvPath, err)
+	}
+	if asOf.IsZero() {
+		asOf = exportDate(patrons)
+	}
+	if asOf.IsZero() {
+		asOf = time.Now().UTC()
+	}
+	return filterExport(patrons, settings, rules, asOf), asOf, nil
+}
+
+// filterExport applies the tier aliases, rules and grace period of a normal
+// run to parsed patrons, as of asOf.
+func filterExport(patrons []Patron, settings Settings, rules []Rule, asOf time.Time) []Patron {
+	patrons = applyTierAliases(patrons, settings.TierAliases)
+	diag := &Diagnostics{ReferenceDate: asOf}
+	filtered, _, _ := filterPatrons(patrons, rules, asOf, settings.GracePeriodDays, diag)
+	if !settings.GracePeriodKeep {
+		filtered = withoutGracePeriod(filtered, diag)
+	}
+	return filtered
+}
+
+func pledgeOf(p Patron) Money {
+	if p.Values != nil {
+		return p.Values.PledgeAmount
+	}
+	m, _ := parseMoney(p.PledgeAmount, p.Currency)
+	return m
+}
+
+// direction compares two pledges; it is empty when they can't be compared.
+func direction(from, to Money) string {
+	switch {
+	case from.Currency != to.Currency || from.Minor == to.Minor:
+		return ""
+	case to.Minor > from.Minor:
+		return "up"
+	}
+	return "down"
+}
+
+// diffRosters matches the patrons of two exports by user ID, falling back to
+// email when either row has no user ID, and reports who joined, who left and
+// what changed for everyone else. Two rows with different user IDs are never
+// the same patron, even if they share an email. Joined and left patrons are
+// in tier order, the changes by name.
+func diffRosters(before, after []Patron, tierOrder []string) rosterDiff {
+	byID := make(map[string]int)
+	byEmail := make(map[string]int)
+	for i, p := range before {
+		if id := strings.TrimSpace(p.UserID); id != "" {
+			byID[id] = i
+		}
+		if email := strings.ToLower(strings.TrimSpace(p.Email)); email != "" {
+			byEmail[email] = i
+		}
+	}
+	matched := make([]bool, len(before))
+	match := func(p Patron) (int, bool) {
+		if i, ok := byID[strings.TrimSpace(p.UserID)]; ok && !matched[i] {
+			return i, true
+		}
+		i, ok := byEmail[strings.ToLower(strings.TrimSpace(p.Email))]
+		if ok && !matched[i] && (strings.TrimSpace(p.UserID) == "" || strings.TrimSpace(before[i].UserID) == "") {
+			return i, true
+		}
+		return 0, false
+	}
+
+	d := rosterDiff{TierChanges: []patronChange{}, PledgeChanges: []patronChange{}, Renames: []patronChange{}}
+	var added []Patron
+	for _, p := range after {
+		i, ok := match(p)
+		if !ok {
+			added = append(added, p)
+			continue
+		}
+		matched[i] = true
+		was := before[i]
+		id := strings.TrimSpace(p.UserID)
+		oldPledge, newPledge := pledgeOf(was), pledgeOf(p)
+		if oldTier, newTier := strings.TrimSpace(was.Tier), strings.TrimSpace(p.Tier); oldTier != newTier {
+			d.TierChanges = append(d.TierChanges, patronChange{UserID: id, Name: p.Name, Old: oldTier, New: newTier,
+				Direction: direction(oldPledge, newPledge)})
+		}
+		if oldPledge != newPledge {
+			d.PledgeChanges = append(d.PledgeChanges, patronChange{UserID: id, Name: p.Name, Old: oldPledge.String(), New: newPledge.String(),
+				Direction: direction(oldPledge, newPledge)})
+		}
+		if was.Name != p.Name {
+			d.Renames = append(d.Renames, patronChange{UserID: id, Name: p.Name, Old: was.Name, New: p.Name})
+		}
+	}
+	var removed []Patron
+	for i, p := range before {
+		if !matched[i] {
+			removed = append(removed, p)
+		}
+	}
+
+	d.Added = diffPatrons(added, tierOrder)
+	d.Removed = diffPatrons(removed, tierOrder)
+	for _, changes := range [][]patronChange{d.TierChanges, d.PledgeChanges, d.Renames} {
+		sort.SliceStable(changes, func(i, j int) bool {
+			return strings.ToLower(changes[i].Name) < strings.ToLower(changes[j].Name)
+		})
+	}
+	return d
+}
+
+// diffPatrons lists patrons in tier order and by name within a tier.
+func diffPatrons(patrons []Patron, tierOrder []string) []diffPatron {
+	out := []diffPatron{}
+	for _, t := range orderTiers(groupAndSortByTier(patrons), tierOrder) {
+		for _, p := range t.Patrons {
+			out = append(out, diffPatron{UserID: strings.TrimSpace(p.UserID), Name: p.Name, Tier: t.Name, Pledge: pledgeOf(p).String()})
+		}
+	}
+	return out
+}
+
+// writeDiffText writes the report for reading aloud or pasting into chat.
+func writeDiffText(w io.Writer, d rosterDiff) {
+	fmt.Fprintf(w, "Changes from %s (%s) to %s (%s)\n", d.OldFile, d.OldDate, d.NewFile, d.NewDate)
+	patrons := func(title string, list []diffPatron) {
+		fmt.Fprintf(w, "\n%s (%d):\n", title, len(list))
+		for _, p := range list {
+			fmt.Fprintf(w, "  %s (%s, %s)\n", p.Name, p.Tier, p.Pledge)
+		}
+	}
+	changes := func(title string, list []patronChange) {
+		fmt.Fprintf(w, "\n%s (%d):\n", title, len(list))
+		for _, c := range list {
+			suffix := ""
+			if c.Direction != "" {
+				suffix = " (" + c.Direction + ")"
+			}
+			fmt.Fprintf(w, "  %s: %s -> %s%s\n", c.Name, c.Old, c.New, suffix)
+		}
+	}
+	patrons("New patrons", d.Added)
+	patrons("Left", d.Removed)
+	changes("Tier changes", d.TierChanges)
+	changes("Pledge changes", d.PledgeChanges)
+	changes("Renamed", d.Renames)
+}
+
+// runDiff implements "diff [-format text|json] [-o file] old.csv new.csv".
+func runDiff(args []string, settings Settings, rules []Rule) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", "text", "report format: text or json")
+	out := fs.String("o", "", "write the report to this file instead of the console")
+	oldAsOf := fs.String("old-as-of", "", "reference date for the older export (default: its newest Last Updated date)")
+	newAsOf := fs.String("new-as-of", "", "reference date for the newer export (default: its newest Last Updated date)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [-format text|json] [-o file] old.csv new.csv")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("-format must be text or json")
+	}
+	var dates [2]time.Time
+	for i, s := range []string{*oldAsOf, *newAsOf} {
+		t, err := parsePatreonDate(s)
+		if err != nil {
+			return err
+		}
+		dates[i] = t
+	}
+
+	before, oldDate, err := loadFilteredExport(fs.Arg(0), settings, rules, dates[0])
+	if err != nil {
+		return err
+	}
+	after, newDate, err := loadFilteredExport(fs.Arg(1), settings, rules, dates[1])
+	if err != nil {
+		return err
+	}
+	d := diffRosters(before, after, settings.TierOrder)
+	d.OldFile, d.NewFile = filepath.Base(fs.Arg(0)), filepath.Base(fs.Arg(1))
+	d.OldDate, d.NewDate = oldDate.Format("2006-01-02"), newDate.Format("2006-01-02")
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	writeDiffText(w, d)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffRosters(t *testing.T) {
+	before := []Patron{
+		{UserID: "1", Name: "Alice", Tier: "Silver", PledgeAmount: "5.00", Currency: "USD"},
+		{UserID: "2", Name: "Bob", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+		{Name: "Carol", Email: "carol@example.com", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+		{UserID: "4", Name: "Dan", Tier: "Silver", PledgeAmount: "5.00", Currency: "USD"},
+	}
+	after := []Patron{
+		{UserID: "1", Name: "Alice", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+		{UserID: "2", Name: "Robert", Tier: "Gold", PledgeAmount: "12.00", Currency: "USD"},
+		{UserID: "3", Name: "Carol", Email: "Carol@Example.com", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+		{UserID: "5", Name: "Erin", Tier: "Silver", PledgeAmount: "5.00", Currency: "USD"},
+		{UserID: "6", Name: "Frank", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"},
+	}
+	d := diffRosters(before, after, []string{"Gold", "Silver"})
+
+	var added []string
+	for _, p := range d.Added {
+		added = append(added, p.Name)
+	}
+	if got := strings.Join(added, ","); got != "Frank,Erin" {
+		t.Errorf("added = %s, want Frank,Erin (tier order)", got)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "Dan" {
+		t.Errorf("removed = %+v, want Dan", d.Removed)
+	}
+	if len(d.TierChanges) != 1 || d.TierChanges[0] != (patronChange{UserID: "1", Name: "Alice", Old: "Silver", New: "Gold", Direction: "up"}) {
+		t.Errorf("tier changes = %+v", d.TierChanges)
+	}
+	if len(d.PledgeChanges) != 2 || d.PledgeChanges[1].Name != "Robert" || d.PledgeChanges[1].Old != "10.00 USD" || d.PledgeChanges[1].New != "12.00 USD" {
+		t.Errorf("pledge changes = %+v", d.PledgeChanges)
+	}
+	if len(d.Renames) != 1 || d.Renames[0].Old != "Bob" || d.Renames[0].New != "Robert" {
+		t.Errorf("renames = %+v", d.Renames)
+	}
+
+	var buf bytes.Buffer
+	writeDiffText(&buf, d)
+	for _, want := range []string{"New patrons (2):\n  Frank (Gold, 10.00 USD)\n", "Alice: Silver -> Gold (up)", "Robert: Bob -> Robert"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text report is missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDiffRosters_SharedEmailDifferentIDs(t *testing.T) {
+	before := []Patron{{UserID: "1", Name: "Alice", Email: "family@example.com", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD"}}
+	after := []Patron{{UserID: "2", Name: "Ann", Email: "family@example.com", Tier: "Silver", PledgeAmount: "5.00", Currency: "USD"}}
+	d := diffRosters(before, after, nil)
+	if len(d.Added) != 1 || d.Added[0].Name != "Ann" || len(d.Removed) != 1 || d.Removed[0].Name != "Alice" {
+		t.Errorf("expected Ann added and Alice removed, got added %+v, removed %+v", d.Added, d.Removed)
+	}
+	if len(d.TierChanges) != 0 || len(d.PledgeChanges) != 0 || len(d.Renames) != 0 {
+		t.Errorf("expected no changes between different patrons, got %+v", d)
+	}
+}
+
+func TestLoadFilteredExportUsesExportDate(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "old.csv")
+	csv := "Name,Tier,Last Charge Status,Access Expiration,Last Updated\n" +
+		"Alice,Gold,Paid,2023-02-01 00:00:00,2023-01-15 00:00:00\n" +
+		"Bob,Gold,Paid,2023-01-10 00:00:00,2023-01-05 00:00:00\n"
+	if err := os.WriteFile(csvPath, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings := defaultSettings()
+	rules, err := buildRules(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patrons, asOf, err := loadFilteredExport(csvPath, settings, rules, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC); !asOf.Equal(want) {
+		t.Errorf("reference date = %v, want %v", asOf, want)
+	}
+	if len(patrons) != 1 || patrons[0].Name != "Alice" {
+		t.Errorf("patrons = %+v, want only Alice", patrons)
+	}
+}
+
+func TestDiffSubcommandJSONStdout(t *testing.T) {
+	dir := t.TempDir()
+	header := "Name,Tier,Patron Status,Last Charge Status,User ID,Pledge Amount,Currency,Last Updated\n"
+	files := map[string]string{
+		"old.csv": header + "Alice,Gold,Active patron,Paid,1,10.00,USD,2024-04-01 00:00:00\n",
+		"new.csv": header + "Alice,Gold,Active patron,Paid,1,10.00,USD,2024-05-01 00:00:00\n" +
+			"Bob,Gold,Active patron,Paid,2,10.00,USD,2024-05-01 00:00:00\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldArgs, oldFlags, oldStdout, oldStderr := os.Args, flag.CommandLine, os.Stdout, os.Stderr
+	defer func() {
+		os.Chdir(oldDir)
+		os.Args, flag.CommandLine, os.Stdout, os.Stderr = oldArgs, oldFlags, oldStdout, oldStderr
+	}()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Args = []string{"patreon-exporter", "diff", "-format", "json", "old.csv", "new.csv"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	os.Stdout, os.Stderr = w, devNull
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- data
+	}()
+	main()
+	w.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	data := <-output
+
+	var d rosterDiff
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, data)
+	}
+	if len(d.Added) != 1 || d.Added[0].Name != "Bob" {
+		t.Errorf("added = %+v, want Bob", d.Added)
+	}
+}
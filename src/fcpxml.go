@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// basicTitleUID is Final Cut Pro's built-in "Basic Title", which Final Cut and
+// DaVinci Resolve both import as an editable text clip.
+const basicTitleUID = ".../Titles.localized/Bumper:Opener.localized/Basic Title.localized/Basic Title.moti"
+
+// creditsTitle is one title clip: a tier, or the part of a tier that fits on
+// one screen, as lines of text.
+type creditsTitle struct {
+	Name  string
+	Lines []layoutItem
+	Names int
+}
+
+// creditsTitles splits tiers into screens of SVG_WIDTH x SVG_HEIGHT using the
+// sections layout, with one title per tier on each screen.
+func creditsTitles(tiers []TierGroup, settings Settings) []creditsTitle {
+	s := settings
+	s.SVGLayout = "sections"
+	s.MaxPageHeight = settings.CanvasHeight
+	s.MaxNamesPerPage = 0
+	var titles []creditsTitle
+	for _, page := range paginateTiers(tiers, s) {
+		layout := buildLayout(page, s)
+		for _, tier := range page {
+			title := creditsTitle{Name: tier.Name, Names: len(tier.Patrons)}
+			if tier.Continued {
+				title.Name += " (continued)"
+			}
+			for _, item := range layout.Items {
+				if item.Tier == tier.Name {
+					title.Lines = append(title.Lines, item)
+				}
+			}
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// ExportFCPXML writes the credits as a Final Cut Pro XML project of editable
+// title clips, one per tier and screen, one after another. The clips share the
+// SVG_SCROLL_* credits duration in proportion to how many names they hold, on
+// a PNG_FPS timeline.
+func ExportFCPXML(tiers []TierGroup, outputPath string, settings Settings) error {
+	titles := creditsTitles(tiers, settings)
+	fps := settings.PNGFPS
+	total := int(math.Round((settings.ScrollHoldStart + settings.ScrollDuration + settings.ScrollHoldEnd) * float64(fps)))
+	names := 0
+	for _, t := range titles {
+		names += t.Names
+	}
+	if names == 0 {
+		return fmt.Errorf("no names to put in FCPXML titles")
+	}
+	frames := func(n int) string {
+		return fmt.Sprintf("%d/%ds", n, fps)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<!DOCTYPE fcpxml>`)
+	if settings.AsOfDate != "" {
+		fmt.Fprintf(w, "<!-- reference date: %s -->\n", escapeXML(settings.AsOfDate))
+	}
+	fmt.Fprintln(w, `<fcpxml version="1.9">`)
+	fmt.Fprintln(w, `  <resources>`)
+	fmt.Fprintf(w, "    <format id=\"r1\" frameDuration=\"%s\" width=\"%d\" height=\"%d\"/>\n", frames(1), settings.Width, settings.CanvasHeight)
+	fmt.Fprintf(w, "    <effect id=\"r2\" name=\"Basic Title\" uid=\"%s\"/>\n", escapeXML(basicTitleUID))
+	fmt.Fprintln(w, `  </resources>`)
+	fmt.Fprintln(w, `  <library>`)
+	fmt.Fprintln(w, `    <event name="Patreon credits">`)
+	fmt.Fprintln(w, `      <project name="Patreon credits">`)
+	fmt.Fprintf(w, "        <sequence format=\"r1\" duration=\"%s\" tcStart=\"0s\" tcFormat=\"NDF\">\n", frames(total))
+	fmt.Fprintln(w, `          <spine>`)
+
+	// Hand out whole frames by running total, so the clips add up exactly.
+	offset, done, style := 0, 0, 0
+	for _, title := range titles {
+		done += title.Names
+		end := total * done / names
+		fmt.Fprintf(w, "            <title ref=\"r2\" name=\"%s\" offset=\"%s\" start=\"0s\" duration=\"%s\">\n",
+			escapeXML(title.Name), frames(offset), frames(end-offset))
+		offset = end
+
+		// One text-style per line, so each name keeps its own colour.
+		var runs, defs []string
+		for i, line := range title.Lines {
+			style++
+			text := line.Text
+			if i < len(title.Lines)-1 {
+				text += "\n"
+			}
+			runs = append(runs, fmt.Sprintf(`<text-style ref="ts%d">%s</text-style>`, style, escapeXML(text)))
+			bold := ""
+			if line.Heading {
+				bold = ` bold="1"`
+			}
+			c, err := parseColor(line.Color)
+			if err != nil {
+				return fmt.Errorf("color for %q: %v", line.Text, err)
+			}
+			defs = append(defs, fmt.Sprintf(`<text-style-def id="ts%d"><text-style font="%s" fontSize="%d" fontColor="%s %s %s 1"%s alignment="center" strokeColor="0 0 0 1" strokeWidth="%d"/></text-style-def>`,
+				style, escapeXML(assFontName(settings.FontFamily)), line.FontSize,
+				formatNumber(float64(c.R)/255), formatNumber(float64(c.G)/255), formatNumber(float64(c.B)/255), bold, outlineWidth))
+		}
+		fmt.Fprintf(w, "              <text>%s</text>\n", strings.Join(runs, ""))
+		for _, def := range defs {
+			fmt.Fprintf(w, "              %s\n", def)
+		}
+		fmt.Fprintln(w, `            </title>`)
+	}
+
+	fmt.Fprintln(w, `          </spine>`)
+	fmt.Fprintln(w, `        </sequence>`)
+	fmt.Fprintln(w, `      </project>`)
+	fmt.Fprintln(w, `    </event>`)
+	fmt.Fprintln(w, `  </library>`)
+	fmt.Fprintln(w, `</fcpxml>`)
+	return w.Flush()
+}
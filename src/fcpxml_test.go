@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreditsTitles_SplitsLongTiers(t *testing.T) {
+	settings := scrollTestSettings()
+	settings.CanvasHeight = 400
+	titles := creditsTitles([]TierGroup{namedTier("Gold", 4), namedTier("Silver", 20)}, settings)
+	var names []string
+	total := 0
+	for _, title := range titles {
+		names = append(names, title.Name)
+		total += title.Names
+		if !title.Lines[0].Heading {
+			t.Errorf("expected %s to start with its heading", title.Name)
+		}
+	}
+	if got := strings.Join(names, ", "); got != "Gold, Silver, Silver (continued)" {
+		t.Errorf("unexpected titles: %s", got)
+	}
+	if total != 24 {
+		t.Errorf("expected every name in a title, got %d", total)
+	}
+}
+
+func TestExportFCPXML_NoNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credits.fcpxml")
+	for _, tiers := range [][]TierGroup{nil, {{Name: "Gold"}}} {
+		if err := ExportFCPXML(tiers, path, scrollTestSettings()); err == nil {
+			t.Errorf("expected an error for %v", tiers)
+		}
+	}
+}
+
+func TestExportFCPXML(t *testing.T) {
+	settings := scrollTestSettings()
+	settings.CanvasHeight = 400
+	settings.PNGFPS = 25
+	settings.ScrollDuration = 10
+	settings.RandomizeSVGColors = false
+	settings.ColumnColors = []string{"#ff0000"}
+	settings.AsOfDate = "2024-05-01T00:00:00Z"
+	path := filepath.Join(t.TempDir(), "credits.fcpxml")
+	if err := ExportFCPXML([]TierGroup{namedTier("Gold", 4), namedTier("Silver", 20)}, path, settings); err != nil {
+		t.Fatalf("ExportFCPXML failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Format struct {
+			FrameDuration string `xml:"frameDuration,attr"`
+		} `xml:"resources>format"`
+		Sequence struct {
+			Duration string `xml:"duration,attr"`
+			Titles   []struct {
+				Name     string   `xml:"name,attr"`
+				Offset   string   `xml:"offset,attr"`
+				Duration string   `xml:"duration,attr"`
+				Runs     []string `xml:"text>text-style"`
+			} `xml:"spine>title"`
+		} `xml:"library>event>project>sequence"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if doc.Format.FrameDuration != "1/25s" || doc.Sequence.Duration != "250/25s" {
+		t.Errorf("unexpected timing: %+v", doc)
+	}
+	titles := doc.Sequence.Titles
+	if len(titles) != 3 {
+		t.Fatalf("expected 3 titles, got %d", len(titles))
+	}
+	// Gold has 4 of the 24 names, so it gets a sixth of the 250 frames.
+	if titles[0].Name != "Gold" || titles[0].Offset != "0/25s" || titles[0].Duration != "41/25s" {
+		t.Errorf("unexpected first title: %+v", titles[0])
+	}
+	if titles[1].Offset != "41/25s" {
+		t.Errorf("expected the second title to follow the first, got %s", titles[1].Offset)
+	}
+	if titles[0].Runs[0] != "Gold\n" || titles[0].Runs[1] != "Gold patron 0\n" {
+		t.Errorf("unexpected text: %q", titles[0].Runs)
+	}
+	if !strings.Contains(string(data), `fontColor="1 0 0 1" alignment="center"`) {
+		t.Error("expected the column colour on the names")
+	}
+	if !strings.Contains(string(data), "<!-- reference date: 2024-05-01T00:00:00Z -->") {
+		t.Error("expected the reference date in a comment")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// fitResult is the layout chosen by fit mode.
+type fitResult struct {
+	FontSize   int
+	LineHeight int
+	Columns    int
+	Fits       bool // false when even the smallest font and most columns overflow
+}
+
+// fitSettings searches for the largest font size, and then the fewest columns,
+// at which every name fits inside the SVG_WIDTH x SVG_HEIGHT canvas without
+// overflowing its column. Line height keeps the SVG_LINEHEIGHT/SVG_FONTSIZE
+// ratio. Tiers with their own SVG_TIER_FONTSIZES or SVG_TIER_COLUMNS keep them.
+func fitSettings(tiers []TierGroup, settings Settings) (Settings, fitResult) {
+	try := func(fontSize, columns int) (Settings, bool) {
+		s := settings
+		s.FontSize = fontSize
+		s.LineHeight = fontSize * settings.LineHeight / settings.FontSize
+		if s.LineHeight < fontSize {
+			s.LineHeight = fontSize
+		}
+		s.Columns = columns
+		s.Overflow = OverflowFail
+		layout := buildLayout(tiers, s)
+		s.Overflow = settings.Overflow
+		return s, len(layout.Overflow) == 0 && layout.Height <= settings.CanvasHeight
+	}
+
+	for fontSize := settings.MaxFontSize; fontSize >= settings.MinFontSize; fontSize-- {
+		for columns := 1; columns <= settings.MaxColumns; columns++ {
+			if s, ok := try(fontSize, columns); ok {
+				return s, fitResult{FontSize: s.FontSize, LineHeight: s.LineHeight, Columns: columns, Fits: true}
+			}
+		}
+	}
+	s, _ := try(settings.MinFontSize, settings.MaxColumns)
+	return s, fitResult{FontSize: s.FontSize, LineHeight: s.LineHeight, Columns: s.Columns}
+}
+
+// metadata returns the chosen values as SVG metadata attributes.
+func (r fitResult) metadata() []string {
+	return []string{
+		"fit-font-size", strconv.Itoa(r.FontSize),
+		"fit-line-height", strconv.Itoa(r.LineHeight),
+		"fit-columns", strconv.Itoa(r.Columns),
+		"fit-complete", strconv.FormatBool(r.Fits),
+	}
+}
+
+func (r fitResult) String() string {
+	s := fmt.Sprintf("font size %d, line height %d, %d column(s)", r.FontSize, r.LineHeight, r.Columns)
+	if !r.Fits {
+		s += " (names still do not fit the canvas)"
+	}
+	return s
+}
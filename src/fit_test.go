@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fitTestSettings() Settings {
+	settings := defaultSettings()
+	settings.Fit = true
+	settings.Width = 1920
+	settings.CanvasHeight = 1080
+	settings.MinFontSize = 8
+	settings.MaxFontSize = 48
+	return settings
+}
+
+func manyPatrons(n int) []TierGroup {
+	var patrons []Patron
+	for i := 0; i < n; i++ {
+		patrons = append(patrons, Patron{Name: fmt.Sprintf("Patron number %d", i)})
+	}
+	return []TierGroup{{Name: "Gold", Patrons: patrons}}
+}
+
+func TestFitSettings_FewNamesUseLargestFont(t *testing.T) {
+	_, result := fitSettings(manyPatrons(3), fitTestSettings())
+	if !result.Fits || result.FontSize != 48 || result.Columns != 1 {
+		t.Errorf("unexpected fit: %+v", result)
+	}
+	if result.LineHeight != 60 {
+		t.Errorf("expected line height to keep the 20/16 ratio, got %d", result.LineHeight)
+	}
+}
+
+func TestFitSettings_ManyNames(t *testing.T) {
+	settings := fitTestSettings()
+	tiers := manyPatrons(300)
+	fitted, result := fitSettings(tiers, settings)
+	if !result.Fits {
+		t.Fatalf("expected 300 names to fit, got %+v", result)
+	}
+	if result.FontSize >= 48 || result.Columns < 2 {
+		t.Errorf("expected a smaller font and several columns, got %+v", result)
+	}
+	fitted.Overflow = OverflowFail
+	layout := buildLayout(tiers, fitted)
+	if layout.Height > settings.CanvasHeight || len(layout.Overflow) > 0 {
+		t.Errorf("fitted layout does not fit: height %d, overflow %v", layout.Height, layout.Overflow)
+	}
+
+	// One size larger must not fit with any column count.
+	for columns := 1; columns <= settings.MaxColumns; columns++ {
+		s := settings
+		s.FontSize = result.FontSize + 1
+		s.LineHeight = s.FontSize * settings.LineHeight / settings.FontSize
+		s.Columns = columns
+		s.Overflow = OverflowFail
+		l := buildLayout(tiers, s)
+		if l.Height <= settings.CanvasHeight && len(l.Overflow) == 0 {
+			t.Errorf("font size %d with %d columns also fits", s.FontSize, columns)
+		}
+	}
+}
+
+func TestFitSettings_DoesNotFit(t *testing.T) {
+	settings := fitTestSettings()
+	settings.CanvasHeight = 100
+	_, result := fitSettings(manyPatrons(500), settings)
+	if result.Fits || result.FontSize != settings.MinFontSize || result.Columns != settings.MaxColumns {
+		t.Errorf("expected best effort at the smallest size, got %+v", result)
+	}
+}
+
+func TestExportTiersSVG_FitMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all_names.svg")
+	if _, err := ExportTiersSVG(manyPatrons(3), path, fitTestSettings()); err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read SVG file: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{`width="1920" height="1080"`, `fit-font-size="48"`, `fit-columns="1"`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("SVG missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// sfntFont is the small part of a TrueType/OpenType font needed to measure
+// text: the character map and horizontal advances. Kerning is ignored, which
+// makes measured widths very slightly wider than rendered ones.
+type sfntFont struct {
+	data        []byte
+	unitsPerEm  int
+	ascent      int
+	descent     int
+	numHMetrics int
+	hmtx        []byte
+	cmap        []byte // the chosen cmap subtable
+	cmapFormat  int
+
+	// Outlines, only present in fonts with TrueType (glyf) outlines.
+	glyf       []byte
+	loca       []byte
+	longOffset bool // loca holds 32-bit offsets
+}
+
+var (
+	fontCacheMu sync.Mutex
+	fontCache   = map[string]*sfntFont{}
+)
+
+// loadFontCached loads a font file once per run.
+func loadFontCached(path string) (*sfntFont, error) {
+	fontCacheMu.Lock()
+	defer fontCacheMu.Unlock()
+	if f, ok := fontCache[path]; ok {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading font: %v", err)
+	}
+	f, err := parseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %v", path, err)
+	}
+	fontCache[path] = f
+	return f, nil
+}
+
+// parseFont reads the tables we need from a TTF, OTF or the first font of a TTC.
+func parseFont(data []byte) (*sfntFont, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("file too short")
+	}
+	offset := 0
+	if string(data[0:4]) == "ttcf" {
+		if len(data) < 16 {
+			return nil, fmt.Errorf("file too short")
+		}
+		offset = int(binary.BigEndian.Uint32(data[12:16]))
+	}
+	tables, err := readTableDirectory(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	f := &sfntFont{data: data}
+
+	head, ok := tables["head"]
+	if !ok || len(head) < 54 {
+		return nil, fmt.Errorf("missing head table")
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:20]))
+	if f.unitsPerEm == 0 {
+		return nil, fmt.Errorf("invalid unitsPerEm")
+	}
+
+	hhea, ok := tables["hhea"]
+	if !ok || len(hhea) < 36 {
+		return nil, fmt.Errorf("missing hhea table")
+	}
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:6])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:8])))
+	f.numHMetrics = int(binary.BigEndian.Uint16(hhea[34:36]))
+
+	f.hmtx, ok = tables["hmtx"]
+	if !ok || f.numHMetrics == 0 || len(f.hmtx) < f.numHMetrics*4 {
+		return nil, fmt.Errorf("missing or short hmtx table")
+	}
+
+	cmap, ok := tables["cmap"]
+	if !ok {
+		return nil, fmt.Errorf("missing cmap table")
+	}
+	if err := f.chooseCmap(cmap); err != nil {
+		return nil, err
+	}
+
+	f.glyf = tables["glyf"]
+	f.loca = tables["loca"]
+	f.longOffset = binary.BigEndian.Uint16(head[50:52]) == 1
+	return f, nil
+}
+
+func readTableDirectory(data []byte, offset int) (map[string][]byte, error) {
+	if offset+12 > len(data) {
+		return nil, fmt.Errorf("invalid table directory")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[offset+4 : offset+6]))
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := offset + 12 + i*16
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("truncated table directory")
+		}
+		tag := string(data[rec : rec+4])
+		start := int(binary.BigEndian.Uint32(data[rec+8 : rec+12]))
+		length := int(binary.BigEndian.Uint32(data[rec+12 : rec+16]))
+		if start < 0 || length < 0 || start+length > len(data) {
+			return nil, fmt.Errorf("table %s out of range", tag)
+		}
+		tables[tag] = data[start : start+length]
+	}
+	return tables, nil
+}
+
+// chooseCmap picks the best Unicode subtable, preferring full-range format 12.
+func (f *sfntFont) chooseCmap(cmap []byte) error {
+	if len(cmap) < 4 {
+		return fmt.Errorf("invalid cmap table")
+	}
+	n := int(binary.BigEndian.Uint16(cmap[2:4]))
+	bestScore := 0
+	for i := 0; i < n; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[rec : rec+2])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2 : rec+4])
+		off := int(binary.BigEndian.Uint32(cmap[rec+4 : rec+8]))
+		if off+4 > len(cmap) {
+			continue
+		}
+		format := int(binary.BigEndian.Uint16(cmap[off : off+2]))
+		unicode := platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)
+		score := 0
+		switch {
+		case unicode && format == 12:
+			score = 2
+		case unicode && format == 4:
+			score = 1
+		}
+		if score > bestScore {
+			bestScore = score
+			f.cmap = cmap[off:]
+			f.cmapFormat = format
+		}
+	}
+	if bestScore == 0 {
+		return fmt.Errorf("no supported Unicode cmap")
+	}
+	return nil
+}
+
+// glyphIndex maps a rune to a glyph, returning 0 (.notdef) when it is missing.
+func (f *sfntFont) glyphIndex(r rune) int {
+	c := f.cmap
+	switch f.cmapFormat {
+	case 4:
+		if r > 0xFFFF || len(c) < 14 {
+			return 0
+		}
+		segX2 := int(binary.BigEndian.Uint16(c[6:8]))
+		endCodes := 14
+		startCodes := endCodes + segX2 + 2
+		idDeltas := startCodes + segX2
+		idRangeOffsets := idDeltas + segX2
+		if idRangeOffsets+segX2 > len(c) {
+			return 0
+		}
+		for seg := 0; seg < segX2; seg += 2 {
+			end := rune(binary.BigEndian.Uint16(c[endCodes+seg:]))
+			if r > end {
+				continue
+			}
+			start := rune(binary.BigEndian.Uint16(c[startCodes+seg:]))
+			if r < start {
+				return 0
+			}
+			delta := binary.BigEndian.Uint16(c[idDeltas+seg:])
+			rangeOffset := int(binary.BigEndian.Uint16(c[idRangeOffsets+seg:]))
+			if rangeOffset == 0 {
+				return int(uint16(r) + delta)
+			}
+			pos := idRangeOffsets + seg + rangeOffset + int(r-start)*2
+			if pos+2 > len(c) {
+				return 0
+			}
+			g := binary.BigEndian.Uint16(c[pos:])
+			if g == 0 {
+				return 0
+			}
+			return int(g + delta)
+		}
+	case 12:
+		if len(c) < 16 {
+			return 0
+		}
+		groups := int(binary.BigEndian.Uint32(c[12:16]))
+		lo, hi := 0, groups
+		for lo < hi {
+			mid := (lo + hi) / 2
+			g := 16 + mid*12
+			if g+12 > len(c) {
+				return 0
+			}
+			start := rune(binary.BigEndian.Uint32(c[g:]))
+			end := rune(binary.BigEndian.Uint32(c[g+4:]))
+			switch {
+			case r < start:
+				hi = mid
+			case r > end:
+				lo = mid + 1
+			default:
+				return int(binary.BigEndian.Uint32(c[g+8:])) + int(r-start)
+			}
+		}
+	}
+	return 0
+}
+
+// advance returns the horizontal advance of glyph g in font units.
+func (f *sfntFont) advance(g int) int {
+	if g >= f.numHMetrics {
+		g = f.numHMetrics - 1
+	}
+	return int(binary.BigEndian.Uint16(f.hmtx[g*4:]))
+}
+
+// textWidth returns the width of s in pixels at fontSize.
+func (f *sfntFont) textWidth(s string, fontSize float64) float64 {
+	units := 0
+	for _, r := range s {
+		units += f.advance(f.glyphIndex(r))
+	}
+	return float64(units) * fontSize / float64(f.unitsPerEm)
+}
+
+// textMeasurer estimates how wide a line of text is when rendered.
+type textMeasurer interface {
+	textWidth(s string, fontSize float64) float64
+}
+
+// approxMeasurer estimates widths from character classes when no font file
+// is configured. It is tuned to be a little generous for common sans fonts.
+type approxMeasurer struct{}
+
+func (approxMeasurer) textWidth(s string, fontSize float64) float64 {
+	em := 0.0
+	for _, r := range s {
+		switch {
+		case r == ' ':
+			em += 0.3
+		case r == 'i' || r == 'l' || r == 'j' || r == 'I' || r == '.' || r == ',' || r == '\'' || r == '|' || r == '!' || r == ':' || r == ';':
+			em += 0.3
+		case r == 'f' || r == 't' || r == 'r' || r == '(' || r == ')' || r == '-':
+			em += 0.4
+		case r == 'm' || r == 'w' || r == 'M' || r == 'W' || r == '@':
+			em += 0.9
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			em += 0.65
+		case r > 0x2E80:
+			em += 1.0 // CJK and other full-width scripts
+		default:
+			em += 0.55
+		}
+	}
+	return em * fontSize
+}
+
+// measurerFor returns the font-based measurer when SVG_FONT_FILE is set.
+func measurerFor(settings Settings) (textMeasurer, error) {
+	if settings.FontFile == "" {
+		return approxMeasurer{}, nil
+	}
+	return loadFontCached(settings.FontFile)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildSFNT assembles a font file from raw tables.
+func buildSFNT(tables map[string][]byte) []byte {
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	header := make([]byte, 12+16*len(tags))
+	binary.BigEndian.PutUint32(header[0:], 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(len(tags)))
+	var body []byte
+	for i, tag := range tags {
+		rec := header[12+i*16:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(header)+len(body)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(tables[tag])))
+		body = append(body, tables[tag]...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	return append(header, body...)
+}
+
+// simpleGlyph encodes a glyf entry with one contour per slice of points,
+// using 16-bit coordinates throughout.
+func simpleGlyph(contours ...[]glyphPoint) []byte {
+	data := make([]byte, 10)
+	binary.BigEndian.PutUint16(data[0:], uint16(len(contours)))
+	var flags, xs, ys []byte
+	end := -1
+	prevX, prevY := 0, 0
+	for _, contour := range contours {
+		end += len(contour)
+		data = appendUint16(data, uint16(end))
+		for _, p := range contour {
+			flag := byte(0)
+			if p.OnCurve {
+				flag = flagOnCurve
+			}
+			flags = append(flags, flag)
+			xs = appendUint16(xs, uint16(int16(int(p.X)-prevX)))
+			ys = appendUint16(ys, uint16(int16(int(p.Y)-prevY)))
+			prevX, prevY = int(p.X), int(p.Y)
+		}
+	}
+	data = append(data, 0, 0) // no instructions
+	data = append(data, flags...)
+	data = append(data, xs...)
+	data = append(data, ys...)
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// testGlyphs are the outlines of the test font: an empty .notdef, a 400x700
+// box for 'A', a diamond with curved sides for 'B', and glyph 3, a composite
+// of 'A' moved 100 units right.
+func testGlyphs() [][]byte {
+	box := simpleGlyph([]glyphPoint{{100, 0, true}, {100, 700, true}, {500, 700, true}, {500, 0, true}})
+	diamond := simpleGlyph([]glyphPoint{{350, 0, true}, {100, 350, false}, {350, 700, true}, {600, 350, false}})
+	composite := make([]byte, 10, 18)
+	binary.BigEndian.PutUint16(composite[0:], 0xFFFF)
+	composite = appendUint16(composite, compArgsAreWords|compArgsAreXY)
+	composite = appendUint16(composite, 1)
+	composite = appendUint16(composite, 100)
+	composite = appendUint16(composite, 0)
+	return [][]byte{nil, box, diamond, composite}
+}
+
+// testFontTables returns a 1000 unit/em font that maps 'A' to glyph 1
+// (600 units wide) and 'B' to glyph 2 (700 units wide), with the outlines
+// from testGlyphs.
+func testFontTables() map[string][]byte {
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[18:], 1000)
+
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint16(hhea[4:], 800)
+	binary.BigEndian.PutUint16(hhea[6:], uint16(0x10000-200))
+	binary.BigEndian.PutUint16(hhea[34:], 3)
+
+	hmtx := make([]byte, 12)
+	binary.BigEndian.PutUint16(hmtx[0:], 500)
+	binary.BigEndian.PutUint16(hmtx[4:], 600)
+	binary.BigEndian.PutUint16(hmtx[8:], 700)
+
+	// cmap with one format 4 subtable: 'A'..'B' -> 1..2, plus the 0xFFFF end segment.
+	sub := make([]byte, 14+2*4+2+2*4+2*4+2*4)
+	binary.BigEndian.PutUint16(sub[0:], 4)
+	binary.BigEndian.PutUint16(sub[2:], uint16(len(sub)))
+	binary.BigEndian.PutUint16(sub[6:], 4) // segCountX2
+	binary.BigEndian.PutUint16(sub[14:], 'B')
+	binary.BigEndian.PutUint16(sub[16:], 0xFFFF)
+	binary.BigEndian.PutUint16(sub[20:], 'A')
+	binary.BigEndian.PutUint16(sub[22:], 0xFFFF)
+	binary.BigEndian.PutUint16(sub[24:], uint16(0x10000+1-'A'))
+	binary.BigEndian.PutUint16(sub[26:], 1)
+	cmap := make([]byte, 12)
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)
+	binary.BigEndian.PutUint16(cmap[6:], 1)
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	cmap = append(cmap, sub...)
+
+	var glyf, loca []byte
+	for _, g := range testGlyphs() {
+		loca = appendUint16(loca, uint16(len(glyf)/2))
+		glyf = append(glyf, g...)
+	}
+	loca = appendUint16(loca, uint16(len(glyf)/2))
+
+	return map[string][]byte{"head": head, "hhea": hhea, "hmtx": hmtx, "cmap": cmap, "glyf": glyf, "loca": loca}
+}
+
+func TestParseFont_Measure(t *testing.T) {
+	f, err := parseFont(buildSFNT(testFontTables()))
+	if err != nil {
+		t.Fatalf("parseFont failed: %v", err)
+	}
+	if g := f.glyphIndex('A'); g != 1 {
+		t.Errorf("glyphIndex('A') = %d, want 1", g)
+	}
+	if g := f.glyphIndex('Z'); g != 0 {
+		t.Errorf("glyphIndex('Z') = %d, want 0", g)
+	}
+	// 600 + 700 + 500 (.notdef for 'Z') units at 10px on a 1000 unit em.
+	if w := f.textWidth("ABZ", 10); w != 18 {
+		t.Errorf("textWidth = %v, want 18", w)
+	}
+}
+
+func TestParseFont_Invalid(t *testing.T) {
+	if _, err := parseFont([]byte("not a font")); err == nil {
+		t.Error("expected error for garbage data")
+	}
+	tables := testFontTables()
+	delete(tables, "cmap")
+	if _, err := parseFont(buildSFNT(tables)); err == nil || !strings.Contains(err.Error(), "cmap") {
+		t.Errorf("expected missing cmap error, got %v", err)
+	}
+}
+
+func TestLoadSettings_FontFile(t *testing.T) {
+	dir := t.TempDir()
+	fontPath := filepath.Join(dir, "test.ttf")
+	if err := os.WriteFile(fontPath, buildSFNT(testFontTables()), 0644); err != nil {
+		t.Fatalf("failed to write font: %v", err)
+	}
+	settings := defaultSettings()
+	settings.FontFile = fontPath
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, err := measurerFor(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w := m.textWidth("AA", 10); w != 12 {
+		t.Errorf("textWidth = %v, want 12", w)
+	}
+	settings.FontFile = filepath.Join(dir, "missing.ttf")
+	if err := settings.Validate(); err == nil {
+		t.Error("expected error for missing font file")
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// ExportScrollFrames draws the scrolling credits as numbered PNG frames
+// (frame_00001.png, ...) in dir, PNG_FPS frames per second of the
+// SVG_SCROLL_* timeline, at the times given by frameTimes. Each frame is
+// SVG_WIDTH x SVG_HEIGHT at PNG_SCALE.
+// It returns the number of frames written.
+func ExportScrollFrames(tiers []TierGroup, dir string, settings Settings) (int, error) {
+	font, err := outlineFont(settings)
+	if err != nil {
+		return 0, err
+	}
+	background, err := parseColor(settings.PNGBackground)
+	if err != nil {
+		return 0, fmt.Errorf("PNG_BACKGROUND: %v", err)
+	}
+	layout := buildLayout(tiers, settings)
+	if err := overflowError(layout); err != nil {
+		return 0, err
+	}
+	sprites, err := rasterizeLayout(layout, font, settings)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	timeline := newScrollTimeline(layout, settings)
+	times := frameTimes(timeline.Total(), settings.PNGFPS)
+	count := len(times)
+	width := scaled(layout.Width, settings.PNGScale)
+	height := scaled(settings.CanvasHeight, settings.PNGScale)
+
+	// Frames are independent, so draw and encode them in parallel.
+	frames := make(chan int)
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range frames {
+				offset := timeline.Offset(times[i])
+				top := int(math.Round(offset * settings.PNGScale))
+				img := composeSprites(sprites, width, height, top, background)
+				if err := writePNGFile(img, filepath.Join(dir, fmt.Sprintf("frame_%05d.png", i+1)), settings); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		frames <- i
+	}
+	close(frames)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// frameTimes is the time of each frame, 1/fps apart from the start of the
+// timeline. The last frame is at exactly its end, even when the length is not
+// a whole number of frames, so the sequence ends on the final position.
+func frameTimes(total float64, fps int) []float64 {
+	count := int(math.Ceil(total*float64(fps)-1e-9)) + 1
+	times := make([]float64, count)
+	for i := range times {
+		times[i] = math.Min(float64(i)/float64(fps), total)
+	}
+	return times
+}
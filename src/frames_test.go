@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportScrollFrames(t *testing.T) {
+	settings := pngTestSettings(t)
+	settings.PNGFrames = true
+	settings.CanvasHeight = 100
+	settings.ScrollDuration = 1
+	settings.PNGFPS = 4
+	dir := filepath.Join(t.TempDir(), "frames")
+	tiers := []TierGroup{{Name: "Gold", Patrons: []Patron{{Name: "A"}, {Name: "B"}, {Name: "A"}}}}
+
+	n, err := ExportScrollFrames(tiers, dir, settings)
+	if err != nil {
+		t.Fatalf("ExportScrollFrames failed: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("expected 5 frames, got %d", n)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 5 || entries[0].Name() != "frame_00001.png" {
+		t.Fatalf("unexpected frames: %v, %v", entries, err)
+	}
+
+	decode := func(name string) image.Image {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return img
+	}
+	first := decode("frame_00001.png")
+	if b := first.Bounds(); b.Dx() != 100 || b.Dy() != 100 {
+		t.Fatalf("unexpected frame size %v", b)
+	}
+	// The first 'A' spans x 40..60 and y 25..60 before the scroll starts.
+	if c := color.NRGBAModel.Convert(first.At(42, 40)); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the first name in frame 1, got %v", c)
+	}
+	if c := color.NRGBAModel.Convert(first.At(5, 5)).(color.NRGBA); c.A != 0 {
+		t.Errorf("expected a transparent frame, got %v", c)
+	}
+	// Three quarters through, the names have moved 75px up and only the
+	// narrow bottom tip of the 'B' diamond is at that height.
+	late := decode("frame_00004.png")
+	if c := color.NRGBAModel.Convert(late.At(42, 40)).(color.NRGBA); c.A != 0 {
+		t.Errorf("expected the first name to have scrolled away, got %v", c)
+	}
+
+	// The last frame is at the very end of the scroll.
+	timeline := newScrollTimeline(buildLayout(tiers, settings), settings)
+	times := frameTimes(timeline.Total(), settings.PNGFPS)
+	if end := timeline.Offset(times[len(times)-1]); end != float64(timeline.Distance) {
+		t.Errorf("last frame offset = %v, want the full distance %d", end, timeline.Distance)
+	}
+}
+
+func TestFrameTimes(t *testing.T) {
+	times := frameTimes(1.05, 30)
+	if len(times) != 33 || times[31] != 31.0/30 || times[32] != 1.05 {
+		t.Errorf("frameTimes(1.05, 30) = %d frames ending %v", len(times), times[len(times)-2:])
+	}
+	if times := frameTimes(0, 30); len(times) != 1 || times[0] != 0 {
+		t.Errorf("frameTimes(0, 30) = %v", times)
+	}
+}
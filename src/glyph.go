@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// glyphPoint is a point of a TrueType outline in font units, y pointing up.
+// Points that are not on the curve are quadratic Bézier control points.
+type glyphPoint struct {
+	X, Y    float64
+	OnCurve bool
+}
+
+// maxCompositeDepth limits how deeply composite glyphs may nest, so a broken
+// font cannot recurse forever.
+const maxCompositeDepth = 8
+
+// hasOutlines reports whether the font has TrueType outlines to draw. Fonts
+// with PostScript (CFF) outlines can measure text but not render it.
+func (f *sfntFont) hasOutlines() bool {
+	return len(f.glyf) > 0 && len(f.loca) > 0
+}
+
+// glyphData returns the glyf entry of glyph g, empty for blank glyphs.
+func (f *sfntFont) glyphData(g int) ([]byte, error) {
+	var start, end int
+	if f.longOffset {
+		if (g+2)*4 > len(f.loca) {
+			return nil, fmt.Errorf("glyph %d out of range", g)
+		}
+		start = int(binary.BigEndian.Uint32(f.loca[g*4:]))
+		end = int(binary.BigEndian.Uint32(f.loca[g*4+4:]))
+	} else {
+		if (g+2)*2 > len(f.loca) {
+			return nil, fmt.Errorf("glyph %d out of range", g)
+		}
+		start = int(binary.BigEndian.Uint16(f.loca[g*2:])) * 2
+		end = int(binary.BigEndian.Uint16(f.loca[g*2+2:])) * 2
+	}
+	if start > end || end > len(f.glyf) {
+		return nil, fmt.Errorf("glyph %d has an invalid location", g)
+	}
+	return f.glyf[start:end], nil
+}
+
+// glyphContours returns the closed contours of glyph g.
+func (f *sfntFont) glyphContours(g int) ([][]glyphPoint, error) {
+	return f.contours(g, 0)
+}
+
+func (f *sfntFont) contours(g int, depth int) ([][]glyphPoint, error) {
+	data, err := f.glyphData(g)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	if len(data) < 10 {
+		return nil, fmt.Errorf("glyph %d is truncated", g)
+	}
+	numContours := int(int16(binary.BigEndian.Uint16(data[0:2])))
+	if numContours >= 0 {
+		return simpleContours(data[10:], numContours)
+	}
+	if depth >= maxCompositeDepth {
+		return nil, fmt.Errorf("glyph %d nests too deeply", g)
+	}
+	return f.compositeContours(data[10:], depth)
+}
+
+// Simple glyph flags.
+const (
+	flagOnCurve    = 0x01
+	flagXShort     = 0x02
+	flagYShort     = 0x04
+	flagRepeat     = 0x08
+	flagXSameOrPos = 0x10
+	flagYSameOrPos = 0x20
+)
+
+func simpleContours(data []byte, numContours int) ([][]glyphPoint, error) {
+	errTruncated := fmt.Errorf("glyph outline is truncated")
+	if len(data) < numContours*2+2 {
+		return nil, errTruncated
+	}
+	ends := make([]int, numContours)
+	numPoints := 0
+	for i := range ends {
+		ends[i] = int(binary.BigEndian.Uint16(data[i*2:]))
+		if ends[i] < numPoints-1 {
+			return nil, fmt.Errorf("glyph contours are out of order")
+		}
+		numPoints = ends[i] + 1
+	}
+	pos := numContours * 2
+	pos += 2 + int(binary.BigEndian.Uint16(data[pos:])) // skip instructions
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		if pos >= len(data) {
+			return nil, errTruncated
+		}
+		flag := data[pos]
+		pos++
+		flags = append(flags, flag)
+		if flag&flagRepeat != 0 {
+			if pos >= len(data) {
+				return nil, errTruncated
+			}
+			for n := int(data[pos]); n > 0 && len(flags) < numPoints; n-- {
+				flags = append(flags, flag)
+			}
+			pos++
+		}
+	}
+
+	points := make([]glyphPoint, numPoints)
+	readCoords := func(short, sameOrPos byte, set func(i int, v float64)) error {
+		v := 0
+		for i, flag := range flags {
+			switch {
+			case flag&short != 0:
+				if pos >= len(data) {
+					return errTruncated
+				}
+				d := int(data[pos])
+				pos++
+				if flag&sameOrPos == 0 {
+					d = -d
+				}
+				v += d
+			case flag&sameOrPos == 0:
+				if pos+2 > len(data) {
+					return errTruncated
+				}
+				v += int(int16(binary.BigEndian.Uint16(data[pos:])))
+				pos += 2
+			}
+			set(i, float64(v))
+		}
+		return nil
+	}
+	if err := readCoords(flagXShort, flagXSameOrPos, func(i int, v float64) { points[i].X = v }); err != nil {
+		return nil, err
+	}
+	if err := readCoords(flagYShort, flagYSameOrPos, func(i int, v float64) { points[i].Y = v }); err != nil {
+		return nil, err
+	}
+	for i, flag := range flags {
+		points[i].OnCurve = flag&flagOnCurve != 0
+	}
+
+	contours := make([][]glyphPoint, 0, numContours)
+	start := 0
+	for _, end := range ends {
+		contours = append(contours, points[start:end+1])
+		start = end + 1
+	}
+	return contours, nil
+}
+
+// Composite glyph flags.
+const (
+	compArgsAreWords   = 0x0001
+	compArgsAreXY      = 0x0002
+	compHaveScale      = 0x0008
+	compMoreComponents = 0x0020
+	compHaveXYScale    = 0x0040
+	compHaveTwoByTwo   = 0x0080
+)
+
+// compositeContours assembles a glyph made of other glyphs, each moved and
+// optionally scaled. Components positioned by matching points are placed
+// without an offset.
+func (f *sfntFont) compositeContours(data []byte, depth int) ([][]glyphPoint, error) {
+	errTruncated := fmt.Errorf("composite glyph is truncated")
+	var contours [][]glyphPoint
+	pos := 0
+	for {
+		if pos+4 > len(data) {
+			return nil, errTruncated
+		}
+		flags := binary.BigEndian.Uint16(data[pos:])
+		component := int(binary.BigEndian.Uint16(data[pos+2:]))
+		pos += 4
+
+		var dx, dy float64
+		if flags&compArgsAreWords != 0 {
+			if pos+4 > len(data) {
+				return nil, errTruncated
+			}
+			dx = float64(int16(binary.BigEndian.Uint16(data[pos:])))
+			dy = float64(int16(binary.BigEndian.Uint16(data[pos+2:])))
+			pos += 4
+		} else {
+			if pos+2 > len(data) {
+				return nil, errTruncated
+			}
+			dx = float64(int8(data[pos]))
+			dy = float64(int8(data[pos+1]))
+			pos += 2
+		}
+		if flags&compArgsAreXY == 0 {
+			dx, dy = 0, 0
+		}
+
+		// Transform matrix [a b; c d], read as F2Dot14 values.
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		f2dot14 := func() float64 {
+			v := float64(int16(binary.BigEndian.Uint16(data[pos:]))) / 16384
+			pos += 2
+			return v
+		}
+		switch {
+		case flags&compHaveScale != 0:
+			if pos+2 > len(data) {
+				return nil, errTruncated
+			}
+			a = f2dot14()
+			d = a
+		case flags&compHaveXYScale != 0:
+			if pos+4 > len(data) {
+				return nil, errTruncated
+			}
+			a, d = f2dot14(), f2dot14()
+		case flags&compHaveTwoByTwo != 0:
+			if pos+8 > len(data) {
+				return nil, errTruncated
+			}
+			a, b, c, d = f2dot14(), f2dot14(), f2dot14(), f2dot14()
+		}
+
+		parts, err := f.contours(component, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			moved := make([]glyphPoint, len(part))
+			for i, p := range part {
+				moved[i] = glyphPoint{X: a*p.X + c*p.Y + dx, Y: b*p.X + d*p.Y + dy, OnCurve: p.OnCurve}
+			}
+			contours = append(contours, moved)
+		}
+		if flags&compMoreComponents == 0 {
+			return contours, nil
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlyphContours(t *testing.T) {
+	f, err := parseFont(buildSFNT(testFontTables()))
+	if err != nil {
+		t.Fatalf("parseFont failed: %v", err)
+	}
+	if !f.hasOutlines() {
+		t.Fatal("expected the test font to have outlines")
+	}
+
+	empty, err := f.glyphContours(0)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected no contours for .notdef, got %v, %v", empty, err)
+	}
+
+	box, err := f.glyphContours(1)
+	if err != nil {
+		t.Fatalf("glyphContours(1) failed: %v", err)
+	}
+	want := [][]glyphPoint{{{100, 0, true}, {100, 700, true}, {500, 700, true}, {500, 0, true}}}
+	if !reflect.DeepEqual(box, want) {
+		t.Errorf("got %v, want %v", box, want)
+	}
+
+	diamond, err := f.glyphContours(2)
+	if err != nil || len(diamond) != 1 || diamond[0][1].OnCurve || !diamond[0][2].OnCurve {
+		t.Errorf("unexpected diamond: %v, %v", diamond, err)
+	}
+}
+
+func TestGlyphContours_Composite(t *testing.T) {
+	f, err := parseFont(buildSFNT(testFontTables()))
+	if err != nil {
+		t.Fatalf("parseFont failed: %v", err)
+	}
+	moved, err := f.glyphContours(3)
+	if err != nil {
+		t.Fatalf("glyphContours(3) failed: %v", err)
+	}
+	want := [][]glyphPoint{{{200, 0, true}, {200, 700, true}, {600, 700, true}, {600, 0, true}}}
+	if !reflect.DeepEqual(moved, want) {
+		t.Errorf("got %v, want %v", moved, want)
+	}
+	if _, err := f.glyphContours(4); err == nil {
+		t.Error("expected error for a glyph past the end of loca")
+	}
+}
+
+func TestParseFont_WithoutOutlines(t *testing.T) {
+	tables := testFontTables()
+	delete(tables, "glyf")
+	delete(tables, "loca")
+	f, err := parseFont(buildSFNT(tables))
+	if err != nil {
+		t.Fatalf("parseFont failed: %v", err)
+	}
+	if f.hasOutlines() {
+		t.Error("did not expect outlines")
+	}
+}
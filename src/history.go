@@ -0,0 +1,272 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotExt is the extension of the files in HISTORY_DIR.
+const snapshotExt = ".json.gz"
+
+// historySnapshot is the parsed roster of one run, kept so the run can be
+// compared with later ones or regenerated. Patrons are keyed by user ID and
+// hold only their non-empty fields, by patronColumns key.
+type historySnapshot struct {
+	ID            string                       `json:"-"`
+	CreatedAt     string                       `json:"created_at"`
+	ExportDate    string                       `json:"export_date,omitempty"`
+	ReferenceDate string                       `json:"reference_date"`
+	SourceFile    string                       `json:"source_file"`
+	Patrons       map[string]map[string]string `json:"patrons"`
+	// Rows lists the keys of Patrons in export order, with their CSV line.
+	// Snapshots saved before it was added do not have it.
+	Rows []snapshotRow `json:"rows,omitempty"`
+}
+
+// snapshotRow is where a patron of a snapshot was in the export.
+type snapshotRow struct {
+	Key  string `json:"key"`
+	Line int    `json:"line"`
+}
+
+// snapshotKey is the key a patron is stored under: the user ID, or the email
+// or row for the rare rows without one.
+func snapshotKey(p Patron) string {
+	if id := strings.TrimSpace(p.UserID); id != "" {
+		return id
+	}
+	if email := strings.ToLower(strings.TrimSpace(p.Email)); email != "" {
+		return "email:" + email
+	}
+	return fmt.Sprintf("row:%d", p.Line)
+}
+
+// newSnapshot records the parsed patrons of a run.
+func newSnapshot(patrons []Patron, sourceFile string, reference, created time.Time) historySnapshot {
+	s := historySnapshot{
+		ID:            created.UTC().Format("20060102-150405"),
+		CreatedAt:     created.UTC().Format(time.RFC3339),
+		ReferenceDate: reference.Format(time.RFC3339),
+		SourceFile:    sourceFile,
+		Patrons:       make(map[string]map[string]string, len(patrons)),
+	}
+	if t := exportDate(patrons); !t.IsZero() {
+		s.ExportDate = t.Format(time.RFC3339)
+	}
+	for _, p := range patrons {
+		p := p
+		fields := make(map[string]string)
+		for _, c := range patronColumns {
+			if v := *c.Field(&p); v != "" {
+				fields[c.Key] = v
+			}
+		}
+		key := snapshotKey(p)
+		if _, dup := s.Patrons[key]; dup {
+			// Keep every row of the export, even a repeated user ID.
+			key = fmt.Sprintf("row:%d", p.Line)
+		}
+		s.Patrons[key] = fields
+		s.Rows = append(s.Rows, snapshotRow{Key: key, Line: p.Line})
+	}
+	return s
+}
+
+// records turns the snapshot back into CSV rows with a header, so it can go
+// through parsePatrons like a fresh export. Each row goes back on its line of
+// the export; the malformed rows the snapshot does not hold become empty rows,
+// which parsePatrons reports as malformed again. Snapshots without Rows come
+// back in key order.
+func (s historySnapshot) records() [][]string {
+	header := make([]string, len(patronColumns))
+	for i, c := range patronColumns {
+		header[i] = c.Aliases[0]
+	}
+	rows := s.Rows
+	if len(rows) == 0 {
+		for key := range s.Patrons {
+			rows = append(rows, snapshotRow{Key: key})
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	}
+	records := [][]string{header}
+	for _, r := range rows {
+		for len(records) < r.Line-1 {
+			records = append(records, []string{})
+		}
+		row := make([]string, len(patronColumns))
+		for i, c := range patronColumns {
+			row[i] = s.Patrons[r.Key][c.Key]
+		}
+		records = append(records, row)
+	}
+	return records
+}
+
+// saveSnapshot writes s to dir as gzipped JSON and returns the file written.
+// It never replaces an existing snapshot: a second run within the same second
+// gets a numbered ID (20240501-093000-2, ...).
+func saveSnapshot(dir string, s historySnapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	var f *os.File
+	var path string
+	for n := 1; f == nil; n++ {
+		id := s.ID
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", s.ID, n)
+		}
+		path = filepath.Join(dir, id+snapshotExt)
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) && n < 100 {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// loadSnapshot reads the snapshot called id from dir.
+func loadSnapshot(dir, id string) (historySnapshot, error) {
+	id = strings.TrimSuffix(filepath.Base(id), snapshotExt)
+	f, err := os.Open(filepath.Join(dir, id+snapshotExt))
+	if err != nil {
+		return historySnapshot{}, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return historySnapshot{}, fmt.Errorf("%s: %v", id, err)
+	}
+	var s historySnapshot
+	if err := json.NewDecoder(zr).Decode(&s); err != nil {
+		return historySnapshot{}, fmt.Errorf("%s: %v", id, err)
+	}
+	s.ID = id
+	return s, nil
+}
+
+// loadSnapshots reads every snapshot in dir, oldest reference date first.
+// A missing directory has no snapshots.
+func loadSnapshots(dir string) ([]historySnapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+snapshotExt))
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []historySnapshot
+	for _, path := range paths {
+		s, err := loadSnapshot(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].ReferenceDate != snapshots[j].ReferenceDate {
+			return snapshots[i].ReferenceDate < snapshots[j].ReferenceDate
+		}
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// findSnapshotPatron looks a patron up by user ID, then email, then name.
+func findSnapshotPatron(s historySnapshot, query string) (map[string]string, bool) {
+	query = strings.TrimSpace(query)
+	if fields, ok := s.Patrons[query]; ok {
+		return fields, true
+	}
+	for _, field := range []string{"email", "name"} {
+		for _, fields := range s.Patrons {
+			if strings.EqualFold(strings.TrimSpace(fields[field]), query) {
+				return fields, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func dateOnly(rfc3339 string) string {
+	if len(rfc3339) >= len("2006-01-02") {
+		return rfc3339[:len("2006-01-02")]
+	}
+	return rfc3339
+}
+
+// writeSnapshotList prints one line per snapshot.
+func writeSnapshotList(w io.Writer, snapshots []historySnapshot) {
+	if len(snapshots) == 0 {
+		fmt.Fprintln(w, "No snapshots yet.")
+		return
+	}
+	fmt.Fprintf(w, "%-18s %-10s %-10s %7s  %s\n", "Snapshot", "Reference", "Export", "Patrons", "Source")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%-18s %-10s %-10s %7d  %s\n", s.ID, dateOnly(s.ReferenceDate), dateOnly(s.ExportDate), len(s.Patrons), s.SourceFile)
+	}
+}
+
+// writeTierTimeline prints a patron's tier and status in every snapshot,
+// with tier aliases applied, so upgrades, downgrades and gaps show up.
+func writeTierTimeline(w io.Writer, snapshots []historySnapshot, query string, aliases map[string]string) {
+	found := false
+	for _, s := range snapshots {
+		fields, ok := findSnapshotPatron(s, query)
+		if !ok {
+			fmt.Fprintf(w, "%s  -\n", dateOnly(s.ReferenceDate))
+			continue
+		}
+		found = true
+		p := applyTierAliases([]Patron{{Name: fields["name"], Tier: fields["tier"]}}, aliases)[0]
+		tier := strings.TrimSpace(p.Tier)
+		if tier == "" {
+			tier = "(no tier)"
+		}
+		line := fmt.Sprintf("%s  %s", dateOnly(s.ReferenceDate), tier)
+		if status := fields["patron_status"]; status != "" {
+			line += " (" + status + ")"
+		}
+		fmt.Fprintf(w, "%s  %s\n", line, p.Name)
+	}
+	if !found {
+		fmt.Fprintf(w, "No snapshot has a patron matching %q.\n", query)
+	}
+}
+
+// runHistory implements "history [list]" and "history patron <id|email|name>".
+func runHistory(args []string, settings Settings, historyDir string) error {
+	if historyDir == "" {
+		return fmt.Errorf("HISTORY_DIR is not set")
+	}
+	snapshots, err := loadSnapshots(historyDir)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "list" {
+		writeSnapshotList(os.Stdout, snapshots)
+		return nil
+	}
+	if args[0] == "patron" && len(args) == 2 {
+		writeTierTimeline(os.Stdout, snapshots, args[1], settings.TierAliases)
+		return nil
+	}
+	return fmt.Errorf("usage: history [list] | history patron <user id, email or name>")
+}
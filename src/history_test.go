@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	patrons := []Patron{
+		{Name: "Alice", UserID: "1", Tier: "Gold", Email: "alice@example.com", PledgeAmount: "10.00", LastUpdated: "2024-04-30 10:00:00"},
+		{Name: "Bob", Tier: "Silver", Email: "Bob@Example.com", Line: 3},
+	}
+	for i := range patrons {
+		values, _ := parsePatronValues(patrons[i])
+		patrons[i].Values = &values
+	}
+	ref := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)
+	path, err := saveSnapshot(dir, newSnapshot(patrons, "pledges.csv", ref, created))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(path, "20240502-093000.json.gz") {
+		t.Errorf("snapshot written to %s", path)
+	}
+
+	again, err := saveSnapshot(dir, newSnapshot(patrons, "pledges.csv", ref, created))
+	if err != nil || !strings.HasSuffix(again, "20240502-093000-2.json.gz") {
+		t.Errorf("second snapshot in the same second written to %s (%v)", again, err)
+	}
+
+	s, err := loadSnapshot(dir, "20240502-093000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ReferenceDate != "2024-05-01T00:00:00Z" || s.ExportDate != "2024-04-30T10:00:00Z" || s.SourceFile != "pledges.csv" {
+		t.Errorf("snapshot = %+v", s)
+	}
+	if _, ok := s.Patrons["1"]; !ok {
+		t.Errorf("Alice is not keyed by user ID: %v", s.Patrons)
+	}
+	if _, ok := s.Patrons["email:bob@example.com"]; !ok {
+		t.Errorf("Bob is not keyed by email: %v", s.Patrons)
+	}
+
+	parsed, _, err := parsePatrons(s.records(), &Diagnostics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].Name != "Alice" || parsed[0].Tier != "Gold" || parsed[0].PledgeAmount != "10.00" || parsed[1].Email != "Bob@Example.com" {
+		t.Errorf("parsed snapshot = %+v", parsed)
+	}
+}
+
+func TestSnapshotRecordsKeepExportLines(t *testing.T) {
+	records := [][]string{
+		{"Name", "Tier", "Patron Status", "Last Charge Status", "User ID"},
+		{"Zoe", "Gold", "Active patron", "Paid", "9"},
+		{"broken"},
+		{"Adam", "Gold", "Active patron", "Paid", "1"},
+		{"Adam again", "Gold", "Active patron", "Paid", "1"},
+	}
+	lines := func(records [][]string) ([]string, []int) {
+		diag := &Diagnostics{}
+		patrons, _, err := parsePatrons(records, diag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range patrons {
+			got = append(got, fmt.Sprintf("%d:%s", p.Line, p.Name))
+		}
+		var malformed []int
+		for _, e := range diag.Entries {
+			if e.Reason == ReasonMalformed {
+				malformed = append(malformed, e.Line)
+			}
+		}
+		return got, malformed
+	}
+	wantPatrons, wantMalformed := lines(records)
+	patrons, _, _ := parsePatrons(records, &Diagnostics{})
+	s := newSnapshot(patrons, "pledges.csv", time.Now(), time.Now())
+	gotPatrons, gotMalformed := lines(s.records())
+	if !reflect.DeepEqual(gotPatrons, wantPatrons) {
+		t.Errorf("regenerated patrons = %v, want %v", gotPatrons, wantPatrons)
+	}
+	if !reflect.DeepEqual(gotMalformed, wantMalformed) {
+		t.Errorf("regenerated malformed lines = %v, want %v", gotMalformed, wantMalformed)
+	}
+}
+
+func TestTierTimeline(t *testing.T) {
+	snapshot := func(ref string, tier string) historySnapshot {
+		s := historySnapshot{ReferenceDate: ref, Patrons: map[string]map[string]string{}}
+		if tier != "" {
+			s.Patrons["7"] = map[string]string{"name": "Alice", "tier": tier, "patron_status": "Active patron"}
+		}
+		return s
+	}
+	snapshots := []historySnapshot{
+		snapshot("2024-03-01T00:00:00Z", "Silver (old)"),
+		snapshot("2024-04-01T00:00:00Z", ""),
+		snapshot("2024-05-01T00:00:00Z", "Gold"),
+	}
+	var buf bytes.Buffer
+	writeTierTimeline(&buf, snapshots, "alice", map[string]string{"silver (old)": "Silver"})
+	want := "2024-03-01  Silver (Active patron)  Alice\n2024-04-01  -\n2024-05-01  Gold (Active patron)  Alice\n"
+	if buf.String() != want {
+		t.Errorf("timeline =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeTierTimeline(&buf, snapshots, "nobody", nil)
+	if !strings.Contains(buf.String(), `No snapshot has a patron matching "nobody"`) {
+		t.Errorf("timeline for an unknown patron =\n%s", buf.String())
+	}
+}
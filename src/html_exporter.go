@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"os"
+)
+
+// htmlPage is the data an HTML template receives.
+type htmlPage struct {
+	Title         string
+	ReferenceDate string
+	Total         int
+	FontFamily    string
+	HeadingColor  string
+	Tiers         []htmlTier
+}
+
+// htmlTier is one tier of the page, in tier order.
+type htmlTier struct {
+	Name    string
+	Heading string // SVG_TIER_HEADINGS, or the tier name
+	Columns int
+	Patrons []htmlPatron
+}
+
+// htmlPatron is a patron and the colour the SVG gives their name. Every
+// Patron field (.Name, .Tier, ...) can be used in a template.
+type htmlPatron struct {
+	Patron
+	Color string
+}
+
+// defaultHTMLTemplate is a self-contained page with inline CSS and no
+// external assets, so it can be uploaded anywhere as is.
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if .ReferenceDate}}<meta name="reference-date" content="{{.ReferenceDate}}">
+{{end}}<title>{{.Title}}</title>
+<style>
+body { margin: 0; padding: 2em 1em; background: #111; color: #fff; font-family: {{.FontFamily}}; text-align: center; }
+h1 { margin: 0 0 1em; color: {{.HeadingColor}}; }
+h2 { margin: 1.5em 0 0.5em; color: {{.HeadingColor}}; }
+ul { list-style: none; margin: 0 auto; padding: 0; max-width: 60em; }
+li { padding: 0.15em 0; break-inside: avoid; text-shadow: 0 0 2px #000, 0 0 2px #000; }
+footer { margin-top: 3em; color: #888; font-size: 0.8em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Tiers}}<section>
+<h2>{{.Heading}}</h2>
+<ul style="columns: {{.Columns}}">
+{{range .Patrons}}<li style="color: {{.Color}}">{{.Name}}</li>
+{{end}}</ul>
+</section>
+{{end}}<footer>{{.Total}} supporters{{if .ReferenceDate}} as of {{.ReferenceDate}}{{end}}</footer>
+</body>
+</html>
+`
+
+// loadHTMLTemplate parses HTML_TEMPLATE_FILE, or the built-in page when it is not set.
+func loadHTMLTemplate(settings Settings) (*template.Template, error) {
+	if settings.HTMLTemplateFile == "" {
+		return template.New("page").Parse(defaultHTMLTemplate)
+	}
+	data, err := os.ReadFile(settings.HTMLTemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTML template: %v", err)
+	}
+	return template.New("page").Parse(string(data))
+}
+
+// buildHTMLPage collects the tiers with the same headings and column counts as
+// the sections SVG layout. Each name takes its colour from the layout the SVG
+// exporter draws, whichever SVG_LAYOUT is set.
+func buildHTMLPage(tiers []TierGroup, settings Settings) htmlPage {
+	page := htmlPage{
+		Title:        settings.HTMLTitle,
+		FontFamily:   settings.FontFamily,
+		HeadingColor: settings.HeadingColor,
+	}
+	if len(settings.AsOfDate) >= len("2006-01-02") {
+		page.ReferenceDate = settings.AsOfDate[:len("2006-01-02")]
+	}
+	for _, t := range tiers {
+		page.Total += len(t.Patrons)
+	}
+	// A page has no fixed column width, so names too wide for an SVG column
+	// are not an error here; SVG_OVERFLOW=fail lays out like none otherwise.
+	layoutSettings := settings
+	if layoutSettings.Overflow == OverflowFail {
+		layoutSettings.Overflow = OverflowNone
+	}
+	pages, _ := layoutPages(tiers, layoutSettings)
+	colors := pages.nameColors()
+	n := 0
+	for _, t := range tiers {
+		style := tierSectionStyle(settings, t.Name)
+		tier := htmlTier{Name: t.Name, Heading: style.Heading, Columns: style.Columns}
+		for _, p := range t.Patrons {
+			tier.Patrons = append(tier.Patrons, htmlPatron{Patron: p, Color: colors[n]})
+			n++
+		}
+		page.Tiers = append(page.Tiers, tier)
+	}
+	return page
+}
+
+// writeHTMLPage writes the supporters page to outputPath.
+func writeHTMLPage(outputPath string, tiers []TierGroup, settings Settings) error {
+	tmpl, err := loadHTMLTemplate(settings)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := tmpl.Execute(w, buildHTMLPage(tiers, settings)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
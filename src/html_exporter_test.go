@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLPage_Default(t *testing.T) {
+	settings := defaultSettings()
+	settings.RandomizeSVGColors = false
+	settings.ColumnColors = []string{"#3aff22"}
+	settings.UserColorMap = map[string]string{"bob": "#ff0000"}
+	settings.TierHeadings = map[string]string{"gold": "Gold Supporters"}
+	settings.TierColumns = map[string]int{"gold": 2}
+	settings.AsOfDate = "2024-05-01T00:00:00Z"
+	tiers := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Alice"}, {Name: "Bob"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "<Carol>"}}},
+	}
+	path := filepath.Join(t.TempDir(), "supporters.html")
+	if err := writeHTMLPage(path, tiers, settings); err != nil {
+		t.Fatalf("writeHTMLPage failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"<title>Thank you to our supporters</title>",
+		"font-family: Trebuchet MS, Arial, sans-serif;",
+		"<h2>Gold Supporters</h2>",
+		`<ul style="columns: 2">`,
+		`<li style="color: #3aff22">Alice</li>`,
+		`<li style="color: #ff0000">Bob</li>`,
+		`<li style="color: #3aff22">&lt;Carol&gt;</li>`,
+		"3 supporters as of 2024-05-01",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "Gold Supporters") > strings.Index(out, "<h2>Silver</h2>") {
+		t.Error("expected tiers in the given order")
+	}
+}
+
+func TestWriteHTMLPage_Template(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "page.html")
+	tmpl := `{{range .Tiers}}{{.Name}}:{{range .Patrons}} {{.Name}} ({{.Tier}}){{end}};{{end}}`
+	if err := os.WriteFile(tmplPath, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings := defaultSettings()
+	settings.ExportHTML = true
+	settings.HTMLTemplateFile = tmplPath
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(dir, "supporters.html")
+	tiers := []TierGroup{{Name: "Gold", Patrons: []Patron{{Name: "Alice", Tier: "Gold"}}}}
+	if err := writeHTMLPage(path, tiers, settings); err != nil {
+		t.Fatalf("writeHTMLPage failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Gold: Alice (Gold);" {
+		t.Errorf("unexpected output %q", data)
+	}
+
+	if err := os.WriteFile(tmplPath, []byte("{{range}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Validate(); err == nil {
+		t.Error("expected error for a broken template")
+	}
+}
+
+func TestWriteHTMLPage_ColorsMatchFlatSVG(t *testing.T) {
+	settings := defaultSettings()
+	settings.RandomizeSVGColors = false
+	settings.Columns = 3
+	settings.ColumnColors = []string{"#ff0000", "#00ff00", "#0000ff"}
+	var tiers []TierGroup
+	for _, tier := range []string{"Gold", "Silver", "Bronze"} {
+		group := TierGroup{Name: tier}
+		for i := 0; i < 4; i++ {
+			group.Patrons = append(group.Patrons, Patron{Name: fmt.Sprintf("%s %d", tier, i)})
+		}
+		tiers = append(tiers, group)
+	}
+	dir := t.TempDir()
+	svgPath := filepath.Join(dir, "all_names.svg")
+	if _, err := ExportTiersSVG(tiers, svgPath, settings); err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	htmlPath := filepath.Join(dir, "supporters.html")
+	if err := writeHTMLPage(htmlPath, tiers, settings); err != nil {
+		t.Fatalf("writeHTMLPage failed: %v", err)
+	}
+	svg, err := os.ReadFile(svgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svgColors := map[string]string{}
+	for _, m := range regexp.MustCompile(`fill="(#[0-9a-fA-F]+)" stroke="none"[^>]*>([^<]+)</text>`).FindAllStringSubmatch(string(svg), -1) {
+		svgColors[m[2]] = m[1]
+	}
+	htmlColors := map[string]string{}
+	for _, m := range regexp.MustCompile(`<li style="color: ([^"]+)">([^<]+)</li>`).FindAllStringSubmatch(string(html), -1) {
+		htmlColors[m[2]] = m[1]
+	}
+	if len(htmlColors) != 12 {
+		t.Fatalf("expected 12 names in the page, got %d", len(htmlColors))
+	}
+	for name, color := range htmlColors {
+		if svgColors[name] != color {
+			t.Errorf("%s: page colour %s, SVG colour %s", name, color, svgColors[name])
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// creditsLayout is the positioned text of a credits roll. It is computed once
+// and then handed to a renderer, so every output format places names the same way.
+type creditsLayout struct {
+	Width  int
+	Height int
+	Items  []layoutItem
+	Names  int // names laid out; wrapped names count once
+	// Overflow lists text that was too wide for its column. It is only
+	// filled in when SVG_OVERFLOW=fail.
+	Overflow []string
+}
+
+// layoutItem is one line of text. X is the horizontal centre of the text and Y
+// its baseline, matching SVG's text-anchor="middle".
+type layoutItem struct {
+	Text     string
+	X        int
+	Y        int
+	FontSize int
+	Color    string
+	Heading  bool
+	Tier     string
+	Name     int // index of the name in the layout, in tier order; unset on headings
+}
+
+// sectionStyle controls how one block of names is laid out.
+type sectionStyle struct {
+	Heading         string
+	HeadingFontSize int
+	HeadingColor    string
+	FontSize        int
+	LineHeight      int
+	Columns         int
+	Colors          []string
+	Spacing         int // space below the section, before the next one
+}
+
+// defaultSectionStyle is the style of the single-section layout, built from the
+// global SVG_* settings.
+func defaultSectionStyle(settings Settings) sectionStyle {
+	return sectionStyle{
+		HeadingFontSize: settings.HeadingFontSize,
+		HeadingColor:    settings.HeadingColor,
+		FontSize:        settings.FontSize,
+		LineHeight:      settings.LineHeight,
+		Columns:         settings.Columns,
+		Colors:          settings.ColumnColors,
+		Spacing:         settings.SectionSpacing,
+	}
+}
+
+// tierSectionStyle applies the per-tier SVG_TIER_* overrides for tier.
+func tierSectionStyle(settings Settings, tier string) sectionStyle {
+	style := defaultSectionStyle(settings)
+	key := strings.ToLower(strings.TrimSpace(tier))
+	style.Heading = tier
+	if heading, ok := settings.TierHeadings[key]; ok {
+		style.Heading = heading
+	}
+	if size, ok := settings.TierFontSizes[key]; ok {
+		// Keep the global line spacing ratio unless a line height is given too.
+		style.LineHeight = size * settings.LineHeight / settings.FontSize
+		style.FontSize = size
+	}
+	if lineHeight, ok := settings.TierLineHeights[key]; ok {
+		style.LineHeight = lineHeight
+	}
+	if columns, ok := settings.TierColumns[key]; ok {
+		style.Columns = columns
+	}
+	if colors, ok := settings.TierColors[key]; ok {
+		style.Colors = colors
+	}
+	if spacing, ok := settings.TierSpacing[key]; ok {
+		style.Spacing = spacing
+	}
+	return style
+}
+
+// buildLayout lays out the tiers as SVG_LAYOUT asks: one block of columns in
+// tier order, or one section per tier.
+func buildLayout(tiers []TierGroup, settings Settings) creditsLayout {
+	if settings.SVGLayout == "sections" {
+		return layoutSections(tiers, settings)
+	}
+	return layoutFlat(tierNames(tiers), settings)
+}
+
+// layoutFlat places every name in one block of columns, the original layout.
+func layoutFlat(names []string, settings Settings) creditsLayout {
+	r := rand.New(rand.NewSource(int64(len(names))))
+	fitter := newTextFitter(settings)
+	items, height := layoutColumns(names, "", defaultSectionStyle(settings), settings.Margin, 0, r, fitter, settings)
+	return creditsLayout{
+		Width:    settings.Width,
+		Height:   settings.Margin*2 + height,
+		Items:    items,
+		Names:    len(names),
+		Overflow: fitter.overflow,
+	}
+}
+
+// layoutSections stacks one block per tier, each under its own heading.
+func layoutSections(tiers []TierGroup, settings Settings) creditsLayout {
+	total := 0
+	for _, t := range tiers {
+		total += len(t.Patrons)
+	}
+	r := rand.New(rand.NewSource(int64(total)))
+	fitter := newTextFitter(settings)
+
+	layout := creditsLayout{Width: settings.Width}
+	y := settings.Margin
+	for i, tier := range tiers {
+		style := tierSectionStyle(settings, tier.Name)
+		if style.Heading != "" && tier.Continued {
+			style.Heading += " (continued)"
+		}
+		if style.Heading != "" {
+			lines, size := fitter.fit(style.Heading, style.HeadingFontSize, settings.Width-settings.Margin*2)
+			for _, line := range lines {
+				y += size
+				layout.Items = append(layout.Items, layoutItem{
+					Text:     line,
+					X:        settings.Width / 2,
+					Y:        y,
+					FontSize: size,
+					Color:    style.HeadingColor,
+					Heading:  true,
+					Tier:     tier.Name,
+				})
+			}
+			y += style.HeadingFontSize / 2
+		}
+		var names []string
+		for _, p := range tier.Patrons {
+			names = append(names, p.Name)
+		}
+		items, height := layoutColumns(names, tier.Name, style, y, layout.Names, r, fitter, settings)
+		layout.Items = append(layout.Items, items...)
+		layout.Names += len(names)
+		y += height
+		if i < len(tiers)-1 {
+			y += style.Spacing
+		}
+	}
+	layout.Height = y + settings.Margin
+	layout.Overflow = fitter.overflow
+	return layout
+}
+
+// layoutColumns splits names into style.Columns columns, filled top to bottom,
+// starting at top and numbered from first. Names wider than their column are
+// handled by fitter, which may put them on two lines. It returns the items and
+// the height the columns use.
+func layoutColumns(names []string, tier string, style sectionStyle, top int, first int, r *rand.Rand, fitter *textFitter, settings Settings) ([]layoutItem, int) {
+	columns := style.Columns
+	if len(names) == 0 {
+		return nil, 0
+	}
+	nPerCol := (len(names) + columns - 1) / columns
+	colNames := make([][]string, columns)
+	for i, name := range names {
+		col := i / nPerCol
+		if col >= columns {
+			col = columns - 1
+		}
+		colNames[col] = append(colNames[col], name)
+	}
+
+	colors := nameColors(names, columns, style.Colors, r, settings)
+	colWidth := (settings.Width - settings.Margin*2 - settings.ColGap*(columns-1)) / columns
+	var items []layoutItem
+	maxLines := 0
+	i := 0
+	for colIdx, col := range colNames {
+		x := settings.Margin + colIdx*(colWidth+settings.ColGap) + colWidth/2
+		line := 0
+		for _, name := range col {
+			color := colors[i]
+			index := first + i
+			i++
+			texts, size := fitter.fit(name, style.FontSize, colWidth)
+			for _, text := range texts {
+				items = append(items, layoutItem{
+					Text:     text,
+					X:        x,
+					Y:        top + style.FontSize + line*style.LineHeight,
+					FontSize: size,
+					Color:    color,
+					Tier:     tier,
+					Name:     index,
+				})
+				line++
+			}
+		}
+		if line > maxLines {
+			maxLines = line
+		}
+	}
+	return items, maxLines * style.LineHeight
+}
+
+// nameColors picks the colour of each name: USER_COLOR_MAP first, then the
+// colour of its column, or a random one with RANDOMIZE_SVG_COLORS. Names fill
+// columns top to bottom, as in layoutColumns.
+func nameColors(names []string, columns int, colors []string, r *rand.Rand, settings Settings) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	nPerCol := (len(names) + columns - 1) / columns
+	out := make([]string, len(names))
+	for i, name := range names {
+		col := i / nPerCol
+		if col >= columns {
+			col = columns - 1
+		}
+		out[i] = getColorForName(col, colors, settings.RandomizeSVGColors, r, name, settings.UserColorMap)
+	}
+	return out
+}
+
+// Overflow policies for text wider than its column (SVG_OVERFLOW).
+const (
+	OverflowNone     = "none"
+	OverflowShrink   = "shrink"
+	OverflowWrap     = "wrap"
+	OverflowTruncate = "truncate"
+	OverflowFail     = "fail"
+)
+
+// outlineWidth is the extra width the black outline adds around the text.
+const outlineWidth = 2
+
+// textFitter applies the SVG_OVERFLOW policy to text that does not fit.
+type textFitter struct {
+	measure  textMeasurer
+	policy   string
+	minSize  int
+	overflow []string
+}
+
+func newTextFitter(settings Settings) *textFitter {
+	m, err := measurerFor(settings)
+	if err != nil {
+		// Validate has already reported unreadable font files.
+		m = approxMeasurer{}
+	}
+	policy := settings.Overflow
+	if policy == "" {
+		policy = OverflowNone
+	}
+	return &textFitter{measure: m, policy: policy, minSize: settings.MinFontSize}
+}
+
+func (tf *textFitter) fits(text string, size int, maxWidth int) bool {
+	return tf.measure.textWidth(text, float64(size))+outlineWidth <= float64(maxWidth)
+}
+
+// fit returns the line(s) to draw for text in a box maxWidth wide, and the
+// font size to draw them at.
+func (tf *textFitter) fit(text string, size int, maxWidth int) ([]string, int) {
+	if tf.policy == OverflowNone || tf.fits(text, size, maxWidth) {
+		return []string{text}, size
+	}
+	switch tf.policy {
+	case OverflowShrink:
+		for size > tf.minSize && size > 1 && !tf.fits(text, size, maxWidth) {
+			size--
+		}
+		return []string{tf.truncate(text, size, maxWidth)}, size
+	case OverflowWrap:
+		first, rest := tf.wrap(text, size, maxWidth)
+		if rest == "" {
+			return []string{first}, size
+		}
+		return []string{first, tf.truncate(rest, size, maxWidth)}, size
+	case OverflowTruncate:
+		return []string{tf.truncate(text, size, maxWidth)}, size
+	case OverflowFail:
+		tf.overflow = append(tf.overflow, text)
+	}
+	return []string{text}, size
+}
+
+// truncate shortens text until it fits, ending it with an ellipsis.
+func (tf *textFitter) truncate(text string, size int, maxWidth int) string {
+	if tf.fits(text, size, maxWidth) {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		candidate := strings.TrimRight(string(runes[:n]), " ") + "…"
+		if tf.fits(candidate, size, maxWidth) {
+			return candidate
+		}
+	}
+	return "…"
+}
+
+// wrap splits text at the last space that leaves a first line that fits, or
+// at the widest prefix that fits when there is no usable space.
+func (tf *textFitter) wrap(text string, size int, maxWidth int) (string, string) {
+	runes := []rune(text)
+	for i := len(runes) - 1; i > 0; i-- {
+		if runes[i] == ' ' && tf.fits(string(runes[:i]), size, maxWidth) {
+			return string(runes[:i]), strings.TrimSpace(string(runes[i+1:]))
+		}
+	}
+	for n := len(runes) - 1; n > 0; n-- {
+		if tf.fits(string(runes[:n]), size, maxWidth) {
+			return string(runes[:n]), string(runes[n:])
+		}
+	}
+	return text, ""
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLayoutFlat(t *testing.T) {
+	settings := defaultSettings()
+	settings.Columns = 2
+	layout := layoutFlat([]string{"A", "B", "C"}, settings)
+	if len(layout.Items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(layout.Items))
+	}
+	if want := settings.Margin*2 + 2*settings.LineHeight; layout.Height != want {
+		t.Errorf("expected height %d, got %d", want, layout.Height)
+	}
+	if layout.Items[0].X != layout.Items[1].X || layout.Items[2].X <= layout.Items[0].X {
+		t.Errorf("expected A and B in the first column and C in the second: %+v", layout.Items)
+	}
+	if want := settings.Margin + settings.FontSize + settings.LineHeight; layout.Items[1].Y != want {
+		t.Errorf("expected second row baseline %d, got %d", want, layout.Items[1].Y)
+	}
+}
+
+func TestLayoutSections(t *testing.T) {
+	settings := defaultSettings()
+	settings.TierHeadings = map[string]string{"gold": "Gold Supporters"}
+	settings.TierFontSizes = map[string]int{"gold": 32}
+	settings.TierColumns = map[string]int{"gold": 1}
+	settings.TierColors = map[string][]string{"gold": {"#ffd700"}}
+	tiers := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Alice"}, {Name: "Bob"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "Carol"}}},
+	}
+	layout := layoutSections(tiers, settings)
+
+	var headings []string
+	for _, item := range layout.Items {
+		if item.Heading {
+			headings = append(headings, item.Text)
+		}
+	}
+	if strings.Join(headings, ",") != "Gold Supporters,Silver" {
+		t.Errorf("unexpected headings: %v", headings)
+	}
+	for _, item := range layout.Items {
+		switch item.Text {
+		case "Alice", "Bob":
+			if item.FontSize != 32 || item.Color != "#ffd700" || item.X != settings.Width/2 {
+				t.Errorf("unexpected Gold style: %+v", item)
+			}
+		case "Carol":
+			if item.FontSize != settings.FontSize {
+				t.Errorf("unexpected Silver style: %+v", item)
+			}
+		}
+	}
+	for i := 1; i < len(layout.Items); i++ {
+		if layout.Items[i].Tier != layout.Items[i-1].Tier && layout.Items[i].Y <= layout.Items[i-1].Y {
+			t.Errorf("expected %q below %q", layout.Items[i].Text, layout.Items[i-1].Text)
+		}
+	}
+	last := layout.Items[len(layout.Items)-1]
+	if layout.Height < last.Y+settings.Margin {
+		t.Errorf("height %d does not fit last item at %d", layout.Height, last.Y)
+	}
+}
+
+func TestExportTiersSVG_Sections(t *testing.T) {
+	settings := defaultSettings()
+	settings.SVGLayout = "sections"
+	tiers := []TierGroup{{Name: "Gold & Co", Patrons: []Patron{{Name: "Alice"}}}}
+	path := filepath.Join(t.TempDir(), "all_names.svg")
+	if _, err := ExportTiersSVG(tiers, path, settings); err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read SVG file: %v", err)
+	}
+	if !strings.Contains(string(data), `font-weight="bold"`) || !strings.Contains(string(data), "Gold &amp; Co") {
+		t.Errorf("SVG missing tier heading: %s", data)
+	}
+}
+
+// fixedMeasurer makes every character exactly one font size wide.
+type fixedMeasurer struct{}
+
+func (fixedMeasurer) textWidth(s string, fontSize float64) float64 {
+	return float64(len([]rune(s))) * fontSize
+}
+
+func TestTextFitter_Policies(t *testing.T) {
+	// A 10px font in a 102px box fits 10 characters next to the outline.
+	cases := []struct {
+		policy string
+		text   string
+		lines  []string
+		size   int
+	}{
+		{OverflowNone, "Bartholomew Jones", []string{"Bartholomew Jones"}, 10},
+		{OverflowTruncate, "Bartholomew Jones", []string{"Bartholom…"}, 10},
+		{OverflowWrap, "Bart Jones Smith", []string{"Bart Jones", "Smith"}, 10},
+		{OverflowWrap, "Bartholomew", []string{"Bartholome", "w"}, 10},
+		{OverflowShrink, "Bartholomew", []string{"Bartholomew"}, 9},
+		{OverflowShrink, "Bartholomew Jones Smith", []string{"Bartholomew J…"}, 7},
+		{OverflowShrink, "Short", []string{"Short"}, 10},
+	}
+	for _, c := range cases {
+		tf := &textFitter{measure: fixedMeasurer{}, policy: c.policy, minSize: 7}
+		lines, size := tf.fit(c.text, 10, 102)
+		if strings.Join(lines, "|") != strings.Join(c.lines, "|") || size != c.size {
+			t.Errorf("%s(%q) = %q at %d, want %q at %d", c.policy, c.text, lines, size, c.lines, c.size)
+		}
+	}
+
+	tf := &textFitter{measure: fixedMeasurer{}, policy: OverflowFail, minSize: 7}
+	tf.fit("Short", 10, 102)
+	tf.fit("Bartholomew Jones", 10, 102)
+	if len(tf.overflow) != 1 || tf.overflow[0] != "Bartholomew Jones" {
+		t.Errorf("unexpected overflow list: %v", tf.overflow)
+	}
+}
+
+func TestExportTiersSVG_FlatOverflowFail(t *testing.T) {
+	settings := defaultSettings()
+	settings.Width = 300
+	settings.Overflow = OverflowFail
+	names := []string{"Al", "Bartholomew Maximilian Fitzgerald-Wellington III"}
+	path := filepath.Join(t.TempDir(), "all_names.svg")
+	err := exportFlatSVG(names, path, settings)
+	if err == nil || !strings.Contains(err.Error(), "Fitzgerald") {
+		t.Fatalf("expected overflow error naming the long name, got %v", err)
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Error("expected no SVG to be written")
+	}
+
+	settings.Overflow = OverflowWrap
+	layout := layoutFlat(names, settings)
+	if len(layout.Items) <= len(names) {
+		t.Errorf("expected the long name to wrap, got %+v", layout.Items)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -41,6 +42,17 @@ type Patron struct {
 	NextChargeDate     string
 	FullCountryName    string
 	SubscriptionSource string
+
+	// Line is the row number in the source CSV, counting the header as line 1.
+	Line int
+	// InGracePeriod is set by filterPatrons on patrons that were kept only
+	// because a recent declined or pending charge is still within the grace window.
+	InGracePeriod bool
+
+	// Values holds the typed form of the fields above and ParseErrors lists the
+	// fields that could not be parsed. Both are filled in by parsePatrons.
+	Values      *PatronValues
+	ParseErrors []FieldError
 }
 
 func getCSVPath(baseDir string, file string) (string, error) {
@@ -79,6 +91,7 @@ func readCSVFile(csvPath string) ([][]string, error) {
 	}
 	defer file.Close()
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Row length is checked against the header in parsePatrons
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, fmt.Errorf("error reading CSV: %v", err)
@@ -86,79 +99,139 @@ func readCSVFile(csvPath string) ([][]string, error) {
 	return records, nil
 }
 
-func parsePatrons(records [][]string) ([]Patron, int) {
+func parsePatrons(records [][]string, diag *Diagnostics) ([]Patron, int, error) {
 	var patrons []Patron
 	var freeTierCount int
-	for i, record := range records {
-		if i == 0 {
-			continue // Skip header
-		}
-		if len(record) < 30 {
+	if len(records) == 0 {
+		return nil, 0, fmt.Errorf("CSV file has no header row")
+	}
+	index, err := mapColumns(records[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, record := range records[1:] {
+		patron, ok := index.bind(record)
+		patron.Line = i + 2
+		if !ok {
+			diag.add(patron, ActionDropped, ReasonMalformed,
+				fmt.Sprintf("row has %d columns, header has %d", len(record), len(records[0])))
 			continue // Skip malformed rows
 		}
-		patron := Patron{
-			Name:               record[0],
-			Email:              record[1],
-			Discord:            record[2],
-			PatronStatus:       record[3],
-			FollowsYou:         record[4],
-			FreeMember:         record[5],
-			FreeTrial:          record[6],
-			LifetimeAmount:     record[7],
-			PledgeAmount:       record[8],
-			ChargeFrequency:    record[9],
-			Tier:               record[10],
-			Addressee:          record[11],
-			Street:             record[12],
-			City:               record[13],
-			State:              record[14],
-			Zip:                record[15],
-			Country:            record[16],
-			Phone:              record[17],
-			PatronageSinceDate: record[18],
-			LastChargeDate:     record[19],
-			LastChargeStatus:   record[20],
-			AdditionalDetails:  record[21],
-			UserID:             record[22],
-			LastUpdated:        record[23],
-			Currency:           record[24],
-			MaxPosts:           record[25],
-			AccessExpiration:   record[26],
-			NextChargeDate:     record[27],
-			FullCountryName:    record[28],
-			SubscriptionSource: record[29],
-		}
+		values, parseErrors := parsePatronValues(patron)
+		patron.Values = &values
+		patron.ParseErrors = parseErrors
+		diag.addParseErrors(patron)
 		if strings.Contains(patron.Tier, "Free") {
 			freeTierCount++
 		}
 		patrons = append(patrons, patron)
 	}
-	return patrons, freeTierCount
+	return patrons, freeTierCount, nil
 }
 
-func filterPatrons(patrons []Patron, now time.Time) ([]Patron, int, int) {
+// filterPatrons keeps the patrons that pass every rule. The first rule that
+// drops a patron decides the reason recorded in diag; with no rules every
+// patron with a tier is kept. When graceDays is above zero, patrons dropped
+// only by the built-in payment rules are kept if inGracePeriod says so.
+func filterPatrons(patrons []Patron, rules []Rule, now time.Time, graceDays int, diag *Diagnostics) ([]Patron, int, int) {
 	var filteredPatrons []Patron
 	var expiredAccessCount, unpaidStatusCount int
 	for _, patron := range patrons {
-		if strings.Contains(patron.Tier, "Free") {
+		if strings.TrimSpace(patron.Tier) == "" {
+			diag.add(patron, ActionExcluded, ReasonNoTier, "")
 			continue
 		}
-		if strings.ToLower(strings.TrimSpace(patron.LastChargeStatus)) != "paid" {
-			unpaidStatusCount++
-			continue
-		}
-		if patron.AccessExpiration != "" {
-			expiration, err := time.Parse("2006-01-02 15:04:05", patron.AccessExpiration)
-			if err == nil && expiration.Before(now) {
-				expiredAccessCount++
+		var failed *Rule
+		paymentOnly := true
+		for i, rule := range rules {
+			if rule.Keeps(patron, now) {
 				continue
 			}
+			if failed == nil {
+				failed = &rules[i]
+			}
+			if rule.Reason != ReasonUnpaid && rule.Reason != ReasonExpiredAccess {
+				paymentOnly = false
+				break
+			}
+		}
+		if failed == nil {
+			filteredPatrons = append(filteredPatrons, patron)
+			continue
+		}
+		if paymentOnly && inGracePeriod(patron, now, graceDays) {
+			patron.InGracePeriod = true
+			diag.add(patron, ActionWarning, ReasonGracePeriod, graceDetail(patron))
+			filteredPatrons = append(filteredPatrons, patron)
+			continue
+		}
+		switch failed.Reason {
+		case ReasonUnpaid:
+			unpaidStatusCount++
+			diag.add(patron, ActionExcluded, failed.Reason, fmt.Sprintf("last charge status %q", patron.LastChargeStatus))
+		case ReasonExpiredAccess:
+			expiredAccessCount++
+			diag.add(patron, ActionExcluded, failed.Reason, "access expired "+patron.AccessExpiration)
+		case ReasonFreeTier:
+			diag.add(patron, ActionExcluded, failed.Reason, patron.Tier)
+		default:
+			diag.add(patron, ActionExcluded, failed.Reason, failed.Expr)
 		}
-		filteredPatrons = append(filteredPatrons, patron)
 	}
 	return filteredPatrons, expiredAccessCount, unpaidStatusCount
 }
 
+// inGracePeriod reports whether a patron whose last charge was declined or is
+// still pending was charged, or loses access, within graceDays of now.
+func inGracePeriod(p Patron, now time.Time, graceDays int) bool {
+	if graceDays <= 0 {
+		return false
+	}
+	values := p.Typed()
+	if values.ChargeStatus != ChargeStatusDeclined && values.ChargeStatus != ChargeStatusPending {
+		return false
+	}
+	window := time.Duration(graceDays) * 24 * time.Hour
+	within := func(t time.Time) bool {
+		if t.IsZero() {
+			return false
+		}
+		d := now.Sub(t)
+		return d <= window && d >= -window
+	}
+	return within(values.LastChargeDate) || within(values.AccessExpiration)
+}
+
+func graceDetail(p Patron) string {
+	detail := fmt.Sprintf("last charge status %q", p.LastChargeStatus)
+	if p.LastChargeDate != "" {
+		detail += " on " + p.LastChargeDate
+	}
+	if p.AccessExpiration != "" {
+		detail += ", access expires " + p.AccessExpiration
+	}
+	return detail
+}
+
+// withoutGracePeriod drops the patrons filterPatrons kept only for the grace
+// period, turning their grace period warnings in diag into exclusions.
+func withoutGracePeriod(patrons []Patron, diag *Diagnostics) []Patron {
+	if diag != nil {
+		for i, e := range diag.Entries {
+			if e.Reason == ReasonGracePeriod && e.Action == ActionWarning {
+				diag.Entries[i].Action = ActionExcluded
+			}
+		}
+	}
+	var kept []Patron
+	for _, p := range patrons {
+		if !p.InGracePeriod {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
 func groupAndSortByTier(patrons []Patron) map[string][]Patron {
 	tierGroups := make(map[string][]Patron)
 	for _, patron := range patrons {
@@ -176,8 +249,9 @@ func groupAndSortByTier(patrons []Patron) map[string][]Patron {
 	return tierGroups
 }
 
-func writeTierFiles(outputDir string, tierGroups map[string][]Patron) error {
-	for tier, patrons := range tierGroups {
+func writeTierFiles(outputDir string, tiers []TierGroup) error {
+	for _, group := range tiers {
+		tier, patrons := group.Name, group.Patrons
 		filename := strings.ReplaceAll(tier, " ", "_")
 		filename = strings.ReplaceAll(filename, "/", "_")
 		filename = strings.ReplaceAll(filename, "\\", "_")
@@ -200,6 +274,10 @@ func writeTierFiles(outputDir string, tierGroups map[string][]Patron) error {
 }
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "show how many patrons each rule keeps or drops without writing any files")
+	asOf := flag.String("as-of", "", "reference date for expiration and charge decisions, e.g. 2024-05-01 (overrides AS_OF_DATE)")
+	flag.Parse()
+
 	baseDir, err := os.Getwd()
 	if err != nil {
 		fmt.Printf("Error getting current working directory: %v\n", err)
@@ -209,40 +287,164 @@ func main() {
 	}
 
 	settings := LoadSettings("settings.conf")
+	settings.DryRun = settings.DryRun || *dryRun
+	if *asOf != "" {
+		if _, err := parsePatreonDate(*asOf); err != nil {
+			fmt.Printf("Invalid -as-of date: %v\n", err)
+			return
+		}
+		settings.AsOfDate = *asOf
+	}
+	historyDir := ""
+	if settings.HistoryDir != "" {
+		historyDir = filepath.Join(baseDir, settings.HistoryDir)
+	}
+	// "regenerate <snapshot>" replays a stored run with its own reference date.
+	var replay *historySnapshot
+	if flag.Arg(0) == "regenerate" {
+		if flag.NArg() != 2 || historyDir == "" {
+			fmt.Println("usage: regenerate <snapshot> (needs HISTORY_DIR)")
+			return
+		}
+		if *asOf != "" {
+			fmt.Println("regenerate uses the snapshot's reference date; -as-of cannot be combined with it")
+			return
+		}
+		snapshot, err := loadSnapshot(historyDir, flag.Arg(1))
+		if err != nil {
+			fmt.Printf("Error reading snapshot: %v\n", err)
+			return
+		}
+		replay = &snapshot
+		settings.AsOfDate = snapshot.ReferenceDate
+		fmt.Printf("Regenerating snapshot %s of %s\n", snapshot.ID, snapshot.SourceFile)
+	}
+	// Resolve the reference date once so every output records the same value.
+	now := settings.ReferenceDate(time.Now().UTC())
+	settings.AsOfDate = now.Format(time.RFC3339)
+	// On stderr, so "diff -format json" and the like leave stdout parseable.
+	fmt.Fprintf(os.Stderr, "Reference date: %s\n", settings.AsOfDate)
+	rules, err := buildRules(settings)
+	if err != nil {
+		fmt.Printf("Error in settings.conf: %v\n", err)
+		return
+	}
+
+	if flag.Arg(0) == "diff" {
+		if err := runDiff(flag.Args()[1:], settings, rules); err != nil {
+			fmt.Printf("diff: %v\n", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "retention" {
+		if err := runRetention(flag.Args()[1:], settings, rules, historyDir, filepath.Join(baseDir, settings.OutputDir)); err != nil {
+			fmt.Printf("retention: %v\n", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "history" {
+		if err := runHistory(flag.Args()[1:], settings, historyDir); err != nil {
+			fmt.Printf("history: %v\n", err)
+		}
+		return
+	}
 
 	outputDir := filepath.Join(baseDir, settings.OutputDir)
 
-	csvPath, err := getCSVPath(baseDir, settings.DefaultCSVFile)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Print("Press Enter to exit...")
-		fmt.Scanln()
-		return
+	var csvPath string
+	if replay != nil {
+		csvPath = replay.SourceFile
+	} else {
+		csvPath, err = getCSVPath(baseDir, settings.DefaultCSVFile)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Print("Press Enter to exit...")
+			fmt.Scanln()
+			return
+		}
 	}
 
-	if err := confirmAndCleanOutputDir(outputDir); err != nil {
-		fmt.Println(err)
+	if !settings.DryRun {
+		if err := confirmAndCleanOutputDir(outputDir); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var records [][]string
+	if replay != nil {
+		records = replay.records()
+	} else {
+		records, err = readCSVFile(csvPath)
+		if err != nil {
+			fmt.Println(err)
+			fmt.Print("Press Enter to exit...")
+			fmt.Scanln()
+			return
+		}
+	}
+	if len(records) < 2 {
+		fmt.Println("CSV file is empty or has no data rows")
+		fmt.Print("Press Enter to exit...")
+		fmt.Scanln()
 		return
 	}
 
-	records, err := readCSVFile(csvPath)
+	diag := &Diagnostics{ReferenceDate: now}
+	patrons, freeTierCount, err := parsePatrons(records, diag)
 	if err != nil {
 		fmt.Println(err)
 		fmt.Print("Press Enter to exit...")
 		fmt.Scanln()
 		return
 	}
-	if len(records) < 2 {
-		fmt.Println("CSV file is empty or has no data rows")
+	if replay == nil && historyDir != "" && !settings.DryRun {
+		path, err := saveSnapshot(historyDir, newSnapshot(patrons, filepath.Base(csvPath), now, time.Now()))
+		if err != nil {
+			fmt.Printf("Error saving snapshot: %v\n", err)
+		} else {
+			fmt.Printf("Snapshot saved to %s\n", path)
+		}
+	}
+	var parseErrorCount int
+	for _, p := range patrons {
+		if len(p.ParseErrors) > 0 {
+			parseErrorCount++
+		}
+	}
+	patrons = applyTierAliases(patrons, settings.TierAliases)
+	filteredPatrons, expiredAccessCount, unpaidStatusCount := filterPatrons(patrons, rules, now, settings.GracePeriodDays, diag)
+	var gracePatrons []Patron
+	for _, p := range filteredPatrons {
+		if p.InGracePeriod {
+			gracePatrons = append(gracePatrons, p)
+		}
+	}
+	if !settings.GracePeriodKeep {
+		filteredPatrons = withoutGracePeriod(filteredPatrons, diag)
+	}
+	tiers := orderTiers(groupAndSortByTier(filteredPatrons), settings.TierOrder)
+	summary := runSummary{
+		ReferenceDate: settings.AsOfDate,
+		SourceFile:    filepath.Base(csvPath),
+		TotalPaying:   len(filteredPatrons),
+		FreeTier:      freeTierCount,
+		ExpiredAccess: expiredAccessCount,
+		Unpaid:        unpaidStatusCount,
+		CustomRules:   diag.Count(ReasonIncludeRule) + diag.Count(ReasonExcludeRule),
+		Malformed:     diag.Count(ReasonMalformed),
+		Unparseable:   parseErrorCount,
+		GracePeriod:   len(gracePatrons),
+	}
+
+	if settings.DryRun {
+		printRuleStats(ruleStats(patrons, rules, now), len(filteredPatrons))
+		fmt.Println("Dry run: no files were written.")
 		fmt.Print("Press Enter to exit...")
 		fmt.Scanln()
 		return
 	}
 
-	patrons, freeTierCount := parsePatrons(records)
-	filteredPatrons, expiredAccessCount, unpaidStatusCount := filterPatrons(patrons, time.Now().UTC())
-	tierGroups := groupAndSortByTier(filteredPatrons)
-
 	err = os.MkdirAll(outputDir, 0755)
 	if err != nil {
 		fmt.Printf("Error creating output directory: %v\n", err)
@@ -251,37 +453,192 @@ func main() {
 		return
 	}
 
-	var allNames []string
-	for _, patrons := range tierGroups {
-		for _, p := range patrons {
-			allNames = append(allNames, p.Name)
-		}
-	}
+	allNames := tierNames(tiers)
 	if settings.ExportSVG {
-		if len(allNames) > 0 {
+		if svgTiers := visibleTiers(tiers, settings.SVGHideTiers); len(svgTiers) > 0 {
 			svgPath := filepath.Join(outputDir, "all_names.svg")
-			if err := ExportNamesSVG(allNames, svgPath, settings); err != nil {
+			if paths, err := ExportTiersSVG(svgTiers, svgPath, settings); err != nil {
 				fmt.Printf("Error creating SVG: %v\n", err)
+			} else if len(paths) == 1 {
+				fmt.Printf("SVG created at %s\n", paths[0])
 			} else {
-				fmt.Printf("SVG created at %s\n", svgPath)
+				fmt.Printf("%d SVG pages created in %s\n", len(paths), outputDir)
+			}
+			if settings.ScrollSVG {
+				scrollPath := filepath.Join(outputDir, "all_names_scroll.svg")
+				if err := ExportScrollingSVG(svgTiers, scrollPath, settings); err != nil {
+					fmt.Printf("Error creating scrolling SVG: %v\n", err)
+				} else {
+					fmt.Printf("Scrolling SVG created at %s\n", scrollPath)
+				}
 			}
 		}
 	} else {
 		fmt.Println("SVG export disabled in settings.conf; skipping SVG generation.")
 	}
 
+	if settings.ExportPNG {
+		if pngTiers := visibleTiers(tiers, settings.SVGHideTiers); len(pngTiers) > 0 {
+			pngPath := filepath.Join(outputDir, "all_names.png")
+			if paths, err := ExportTiersPNG(pngTiers, pngPath, settings); err != nil {
+				fmt.Printf("Error creating PNG: %v\n", err)
+			} else if len(paths) == 1 {
+				fmt.Printf("PNG created at %s\n", paths[0])
+			} else {
+				fmt.Printf("%d PNG pages created in %s\n", len(paths), outputDir)
+			}
+		}
+	}
+
+	if settings.PNGFrames {
+		if frameTiers := visibleTiers(tiers, settings.SVGHideTiers); len(frameTiers) > 0 {
+			framesDir := filepath.Join(outputDir, "frames")
+			if n, err := ExportScrollFrames(frameTiers, framesDir, settings); err != nil {
+				fmt.Printf("Error creating PNG frames: %v\n", err)
+			} else {
+				fmt.Printf("%d PNG frames created in %s\n", n, framesDir)
+			}
+		}
+	}
+
+	if settings.ExportASS || settings.ExportSRT {
+		subtitleTiers := visibleTiers(tiers, settings.SVGHideTiers)
+		if settings.ExportASS {
+			assPath := filepath.Join(outputDir, "all_names.ass")
+			if err := ExportASS(subtitleTiers, assPath, settings); err != nil {
+				fmt.Printf("Error creating ASS subtitles: %v\n", err)
+			} else {
+				fmt.Printf("ASS subtitles created at %s\n", assPath)
+			}
+		}
+		if settings.ExportSRT {
+			srtPath := filepath.Join(outputDir, "all_names.srt")
+			if err := ExportSRT(subtitleTiers, srtPath, settings); err != nil {
+				fmt.Printf("Error creating SRT subtitles: %v\n", err)
+			} else {
+				fmt.Printf("SRT subtitles created at %s\n", srtPath)
+			}
+		}
+	}
+
+	if settings.ExportFCPXML {
+		if titleTiers := visibleTiers(tiers, settings.SVGHideTiers); len(titleTiers) > 0 {
+			fcpxmlPath := filepath.Join(outputDir, "credits.fcpxml")
+			if err := ExportFCPXML(titleTiers, fcpxmlPath, settings); err != nil {
+				fmt.Printf("Error creating FCPXML: %v\n", err)
+			} else {
+				fmt.Printf("FCPXML titles created at %s\n", fcpxmlPath)
+			}
+		}
+	}
+
 	if settings.ExportTXT {
-		writeTierFiles(outputDir, tierGroups)
+		txtTiers := visibleTiers(tiers, settings.TXTHideTiers)
+		if len(settings.TXTTemplateFiles) > 0 {
+			if err := writeTemplateFiles(outputDir, txtTiers, settings); err != nil {
+				fmt.Printf("Error writing template output: %v\n", err)
+			}
+		} else {
+			writeTierFiles(outputDir, txtTiers)
+		}
 	} else {
 		fmt.Println("TXT export disabled in settings.conf; skipping TXT generation.")
 	}
 
-	var totalPaying = len(allNames)
+	if settings.ExportHTML {
+		htmlPath := filepath.Join(outputDir, "supporters.html")
+		if err := writeHTMLPage(htmlPath, visibleTiers(tiers, settings.HTMLHideTiers), settings); err != nil {
+			fmt.Printf("Error creating HTML page: %v\n", err)
+		} else {
+			fmt.Printf("HTML page created at %s\n", htmlPath)
+		}
+	}
+
+	if settings.ExportJSON {
+		jsonPath := filepath.Join(outputDir, "roster.json")
+		if err := writeRosterJSON(jsonPath, tiers, summary, settings); err != nil {
+			fmt.Printf("Error creating JSON roster: %v\n", err)
+		} else {
+			fmt.Printf("JSON roster created at %s\n", jsonPath)
+		}
+	}
+	if settings.ExportCSV {
+		rosterPath := filepath.Join(outputDir, "roster.csv")
+		if err := writeRosterCSV(rosterPath, tiers, settings); err != nil {
+			fmt.Printf("Error creating CSV roster: %v\n", err)
+		} else {
+			fmt.Printf("CSV roster created at %s\n", rosterPath)
+		}
+	}
+
+	if settings.ExportStats {
+		conv, err := newCurrencyConverter(settings, now)
+		if err != nil {
+			fmt.Printf("Error loading exchange rates: %v\n", err)
+		}
+		stats := buildStats(patrons, tiers, summary, settings.StatsTopCountries, conv)
+		for _, warning := range conv.warnings() {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		mdPath := filepath.Join(outputDir, "stats.md")
+		jsonPath := filepath.Join(outputDir, "stats.json")
+		if err := writeStatsFiles(mdPath, jsonPath, stats); err != nil {
+			fmt.Printf("Error creating statistics: %v\n", err)
+		} else {
+			fmt.Printf("Statistics created at %s and %s\n", mdPath, jsonPath)
+		}
+	}
+
+	if settings.ExportShipping {
+		addresses, err := shippingAddresses(tiers, settings, now)
+		if err != nil {
+			fmt.Printf("Error selecting shipping addresses: %v\n", err)
+		} else {
+			printShippingSummary(os.Stdout, addresses, settings.AsOfDate)
+			shippingPath := filepath.Join(outputDir, "shipping.csv")
+			if err := writeShippingCSV(shippingPath, addresses); err != nil {
+				fmt.Printf("Error creating shipping CSV: %v\n", err)
+			} else {
+				fmt.Printf("Shipping CSV created at %s\n", shippingPath)
+			}
+			paths, err := writeShippingLabels(filepath.Join(outputDir, "shipping_labels.svg"), addresses, settings)
+			for _, path := range paths {
+				fmt.Printf("Shipping labels created at %s\n", path)
+			}
+			if err != nil {
+				fmt.Printf("Error creating shipping labels: %v\n", err)
+			}
+		}
+	}
+
+	if settings.ExportDiagnostics {
+		reportPath := filepath.Join(outputDir, "import_report."+settings.DiagnosticsFormat)
+		if err := writeDiagnostics(reportPath, settings.DiagnosticsFormat, diag); err != nil {
+			fmt.Printf("Error creating import report: %v\n", err)
+		} else {
+			fmt.Printf("Import report with %d entries created at %s\n", len(diag.Entries), reportPath)
+		}
+	}
+
 	fmt.Println("----- Summary -----")
-	fmt.Printf("Total paying patrons: %d\n", totalPaying)
-	fmt.Printf("Total free tier patrons: %d\n", freeTierCount)
-	fmt.Printf("Skipped due to expired access: %d\n", expiredAccessCount)
-	fmt.Printf("Skipped due to unpaid status: %d\n", unpaidStatusCount)
+	fmt.Printf("Reference date: %s\n", summary.ReferenceDate)
+	fmt.Printf("Total paying patrons: %d\n", len(allNames))
+	fmt.Printf("Total free tier patrons: %d\n", summary.FreeTier)
+	fmt.Printf("Skipped due to expired access: %d\n", summary.ExpiredAccess)
+	fmt.Printf("Skipped due to unpaid status: %d\n", summary.Unpaid)
+	fmt.Printf("Skipped by custom rules: %d\n", summary.CustomRules)
+	fmt.Printf("Skipped due to malformed rows: %d\n", summary.Malformed)
+	fmt.Printf("Rows with unparseable values: %d\n", summary.Unparseable)
+	if settings.GracePeriodDays > 0 {
+		action := "kept"
+		if !settings.GracePeriodKeep {
+			action = "left out"
+		}
+		fmt.Printf("In %d-day grace period (%s): %d\n", settings.GracePeriodDays, action, summary.GracePeriod)
+		for _, p := range gracePatrons {
+			fmt.Printf("  - %s (%s): %s\n", p.Name, strings.TrimSpace(p.Tier), graceDetail(p))
+		}
+	}
 	fmt.Println("-------------------")
 	fmt.Println("Processing complete! Your files are in the 'output' directory.")
 	fmt.Print("Press Enter to exit...")
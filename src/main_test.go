@@ -85,7 +85,10 @@ func TestParsePatrons(t *testing.T) {
 		{"Alice", "a@b.com", "", "", "", "", "", "", "", "", "Gold", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", ""},
 		{"Bob", "b@b.com", "", "", "", "", "", "", "", "", "Free", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", ""},
 	}
-	patrons, freeCount := parsePatrons(records)
+	patrons, freeCount, err := parsePatrons(records, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if len(patrons) != 2 {
 		t.Errorf("expected 2 patrons, got %d", len(patrons))
 	}
@@ -94,6 +97,39 @@ func TestParsePatrons(t *testing.T) {
 	}
 }
 
+func TestParsePatrons_ReorderedAndShortRows(t *testing.T) {
+	records := [][]string{
+		{"Tier", "Last Charge Status", "Name", "Email"},
+		{"Gold", "Paid", "Alice", "a@b.com"},
+		{"Silver", "Paid", "Bob"},
+		{"Gold", "Paid"},
+	}
+	patrons, _, err := parsePatrons(records, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(patrons) != 2 {
+		t.Fatalf("expected 2 patrons, got %d", len(patrons))
+	}
+	if patrons[0].Name != "Alice" || patrons[0].Tier != "Gold" || patrons[0].Email != "a@b.com" {
+		t.Errorf("unexpected first patron: %+v", patrons[0])
+	}
+	if patrons[1].Name != "Bob" || patrons[1].Email != "" {
+		t.Errorf("unexpected second patron: %+v", patrons[1])
+	}
+}
+
+func TestParsePatrons_MissingRequiredColumn(t *testing.T) {
+	records := [][]string{
+		{"Name", "Email"},
+		{"Alice", "a@b.com"},
+	}
+	_, _, err := parsePatrons(records, nil)
+	if err == nil || !strings.Contains(err.Error(), "'Tier'") {
+		t.Errorf("expected missing Tier error, got %v", err)
+	}
+}
+
 func TestFilterPatrons(t *testing.T) {
 	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	patrons := []Patron{
@@ -102,7 +138,7 @@ func TestFilterPatrons(t *testing.T) {
 		{Name: "C", Tier: "Silver", LastChargeStatus: "unpaid", AccessExpiration: ""},
 		{Name: "D", Tier: "Gold", LastChargeStatus: "paid", AccessExpiration: "2023-01-01 00:00:00"},
 	}
-	filtered, expired, unpaid := filterPatrons(patrons, now)
+	filtered, expired, unpaid := filterPatrons(patrons, defaultRules(), now, 0, nil)
 	if len(filtered) != 1 || filtered[0].Name != "A" {
 		t.Errorf("unexpected filtered: %v", filtered)
 	}
@@ -114,6 +150,48 @@ func TestFilterPatrons(t *testing.T) {
 	}
 }
 
+func TestFilterPatrons_GracePeriod(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	patrons := []Patron{
+		{Name: "Recent", Tier: "Gold", LastChargeStatus: "Declined", LastChargeDate: "2024-03-05 00:00:00"},
+		{Name: "Old", Tier: "Gold", LastChargeStatus: "Declined", LastChargeDate: "2024-01-05 00:00:00"},
+		{Name: "Pending", Tier: "Gold", LastChargeStatus: "Pending", AccessExpiration: "2024-03-08 00:00:00"},
+		{Name: "Refunded", Tier: "Gold", LastChargeStatus: "Refunded", LastChargeDate: "2024-03-09 00:00:00"},
+		{Name: "FreeDeclined", Tier: "Free", LastChargeStatus: "Declined", LastChargeDate: "2024-03-09 00:00:00"},
+	}
+	diag := &Diagnostics{}
+	filtered, _, unpaid := filterPatrons(patrons, defaultRules(), now, 7, diag)
+	if len(filtered) != 2 || filtered[0].Name != "Recent" || filtered[1].Name != "Pending" {
+		t.Fatalf("unexpected filtered: %v", filtered)
+	}
+	for _, p := range filtered {
+		if !p.InGracePeriod {
+			t.Errorf("expected %s to be marked as in grace period", p.Name)
+		}
+	}
+	if unpaid != 2 {
+		t.Errorf("expected 2 unpaid, got %d", unpaid)
+	}
+	if diag.Count(ReasonGracePeriod) != 2 {
+		t.Errorf("expected 2 grace period entries, got %+v", diag.Entries)
+	}
+
+	kept := withoutGracePeriod(filtered, diag)
+	if len(kept) != 0 {
+		t.Errorf("expected grace period patrons to be left out, got %v", kept)
+	}
+	for _, e := range diag.Entries {
+		if e.Reason == ReasonGracePeriod && e.Action != ActionExcluded {
+			t.Errorf("expected %s to be recorded as excluded, got %q", e.Name, e.Action)
+		}
+	}
+
+	filtered, _, _ = filterPatrons(patrons, defaultRules(), now, 0, nil)
+	if len(filtered) != 0 {
+		t.Errorf("expected no patrons without a grace period, got %v", filtered)
+	}
+}
+
 func TestGroupAndSortByTier(t *testing.T) {
 	patrons := []Patron{
 		{Name: "Charlie", Tier: "Gold"},
@@ -131,9 +209,9 @@ func TestGroupAndSortByTier(t *testing.T) {
 
 func TestWriteTierFiles(t *testing.T) {
 	tmpDir := t.TempDir()
-	groups := map[string][]Patron{
-		"Gold":   {{Name: "Alice"}, {Name: "Bob"}},
-		"Silver": {{Name: "Charlie"}},
+	groups := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Alice"}, {Name: "Bob"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "Charlie"}}},
 	}
 	err := writeTierFiles(tmpDir, groups)
 	if err != nil {
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minNamesAtBreak is the fewest names of a tier left on either side of a page
+// break, so a heading is never stranded at the bottom of a page with one or
+// two names under it, nor one or two names carried over alone.
+const minNamesAtBreak = 3
+
+// paginated reports whether SVG_MAX_PAGE_HEIGHT or SVG_MAX_NAMES_PER_PAGE is set.
+func (s Settings) paginated() bool {
+	return s.MaxPageHeight > 0 || s.MaxNamesPerPage > 0
+}
+
+// paginateTiers splits tiers into pages that respect SVG_MAX_PAGE_HEIGHT and
+// SVG_MAX_NAMES_PER_PAGE. A tier that does not fit on the rest of a page is
+// split, and its remainder is marked Continued so the heading is repeated.
+func paginateTiers(tiers []TierGroup, settings Settings) [][]TierGroup {
+	if !settings.paginated() {
+		return [][]TierGroup{tiers}
+	}
+
+	var pages [][]TierGroup
+	var page []TierGroup
+	count := 0
+	fits := func(candidate []TierGroup, n int) bool {
+		if settings.MaxNamesPerPage > 0 && n > settings.MaxNamesPerPage {
+			return false
+		}
+		return settings.MaxPageHeight <= 0 || buildLayout(candidate, settings).Height <= settings.MaxPageHeight
+	}
+	newPage := func() {
+		pages = append(pages, page)
+		page = nil
+		count = 0
+	}
+
+	for _, tier := range tiers {
+		rest := tier
+		for len(rest.Patrons) > 0 {
+			// Largest number of this tier's names that still fit on the page.
+			n := sort.Search(len(rest.Patrons), func(i int) bool {
+				candidate := append(page[:len(page):len(page)], TierGroup{Name: rest.Name, Patrons: rest.Patrons[:i+1], Continued: rest.Continued})
+				return !fits(candidate, count+i+1)
+			})
+			if n < len(rest.Patrons) {
+				left := len(rest.Patrons) - n
+				if left < minNamesAtBreak && n > minNamesAtBreak {
+					n -= minNamesAtBreak - left
+				}
+				if n < minNamesAtBreak && len(page) > 0 {
+					newPage()
+					continue
+				}
+			}
+			if n == 0 {
+				n = 1 // a single name taller than a page still has to go somewhere
+			}
+			page = append(page, TierGroup{Name: rest.Name, Patrons: rest.Patrons[:n], Continued: rest.Continued})
+			count += n
+			rest = TierGroup{Name: rest.Name, Patrons: rest.Patrons[n:], Continued: true}
+			if len(rest.Patrons) > 0 {
+				newPage()
+			}
+		}
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// creditsPages is a credits roll laid out for rendering: fitted to the canvas
+// when SVG_FIT is on and split into pages when paging is on.
+type creditsPages struct {
+	Settings  Settings // with the font size and columns chosen by SVG_FIT
+	Layouts   []creditsLayout
+	Fit       fitResult
+	paginated bool
+}
+
+// layoutPages lays out tiers once for every renderer. It fails without
+// laying anything out when a name is too wide and SVG_OVERFLOW=fail.
+func layoutPages(tiers []TierGroup, settings Settings) (creditsPages, error) {
+	pages := creditsPages{Settings: settings, paginated: settings.paginated()}
+	if settings.Fit {
+		pages.Settings, pages.Fit = fitSettings(tiers, settings)
+	}
+	for _, page := range paginateTiers(tiers, pages.Settings) {
+		layout := buildLayout(page, pages.Settings)
+		if settings.Fit && !pages.paginated && layout.Height < settings.CanvasHeight {
+			layout.Height = settings.CanvasHeight
+		}
+		// Check every page before writing any, so a failure leaves no partial set.
+		if err := overflowError(layout); err != nil {
+			return pages, err
+		}
+		pages.Layouts = append(pages.Layouts, layout)
+	}
+	return pages, nil
+}
+
+// paths returns the file name of each page: outputPath itself without
+// paging, or outputPath numbered _001, _002, ... with it.
+func (cp creditsPages) paths(outputPath string) []string {
+	if !cp.paginated {
+		return []string{outputPath}
+	}
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	paths := make([]string, len(cp.Layouts))
+	for i := range cp.Layouts {
+		paths[i] = fmt.Sprintf("%s_%03d%s", base, i+1, ext)
+	}
+	return paths
+}
+
+// nameColors returns the colour each name was given, in tier order across
+// every page.
+func (cp creditsPages) nameColors() []string {
+	var colors []string
+	for _, layout := range cp.Layouts {
+		page := make([]string, layout.Names)
+		for _, item := range layout.Items {
+			if !item.Heading {
+				page[item.Name] = item.Color
+			}
+		}
+		colors = append(colors, page...)
+	}
+	return colors
+}
+
+// attrs returns the metadata attributes of page i.
+func (cp creditsPages) attrs(i int) []string {
+	var attrs []string
+	if cp.Settings.Fit {
+		attrs = cp.Fit.metadata()
+	}
+	if cp.paginated {
+		attrs = append(attrs, "page", strconv.Itoa(i+1), "pages", strconv.Itoa(len(cp.Layouts)))
+	}
+	return attrs
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pagingTestSettings() Settings {
+	settings := defaultSettings()
+	settings.SVGLayout = "sections"
+	settings.Width = 800
+	settings.Margin = 10
+	settings.Columns = 1
+	settings.FontSize = 16
+	settings.LineHeight = 20
+	settings.HeadingFontSize = 24
+	settings.SectionSpacing = 40
+	settings.ColumnColors = []string{"#ffffff"}
+	return settings
+}
+
+func namedTier(name string, n int) TierGroup {
+	var patrons []Patron
+	for i := 0; i < n; i++ {
+		patrons = append(patrons, Patron{Name: fmt.Sprintf("%s patron %d", name, i)})
+	}
+	return TierGroup{Name: name, Patrons: patrons}
+}
+
+func pageCounts(pages [][]TierGroup) []string {
+	var out []string
+	for _, page := range pages {
+		var parts []string
+		for _, t := range page {
+			part := fmt.Sprintf("%s:%d", t.Name, len(t.Patrons))
+			if t.Continued {
+				part += "+"
+			}
+			parts = append(parts, part)
+		}
+		out = append(out, strings.Join(parts, " "))
+	}
+	return out
+}
+
+func TestPaginateTiers_Disabled(t *testing.T) {
+	tiers := []TierGroup{namedTier("Gold", 50)}
+	pages := paginateTiers(tiers, pagingTestSettings())
+	if len(pages) != 1 || len(pages[0][0].Patrons) != 50 {
+		t.Errorf("expected a single page, got %v", pageCounts(pages))
+	}
+}
+
+func TestPaginateTiers_NamesPerPage(t *testing.T) {
+	settings := pagingTestSettings()
+	settings.MaxNamesPerPage = 10
+	pages := paginateTiers([]TierGroup{namedTier("Gold", 6), namedTier("Silver", 12)}, settings)
+	got := strings.Join(pageCounts(pages), " | ")
+	want := "Gold:6 Silver:4 | Silver:8+"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPaginateTiers_NoStrandedHeading(t *testing.T) {
+	settings := pagingTestSettings()
+	settings.MaxNamesPerPage = 10
+	// Only two Silver names would fit after Gold, so Silver starts a new page.
+	pages := paginateTiers([]TierGroup{namedTier("Gold", 8), namedTier("Silver", 5)}, settings)
+	got := strings.Join(pageCounts(pages), " | ")
+	want := "Gold:8 | Silver:5"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPaginateTiers_NoLoneCarryOver(t *testing.T) {
+	settings := pagingTestSettings()
+	settings.MaxNamesPerPage = 10
+	// Carrying a single name over would look lost, so three go to the next page.
+	pages := paginateTiers([]TierGroup{namedTier("Gold", 11)}, settings)
+	got := strings.Join(pageCounts(pages), " | ")
+	want := "Gold:8 | Gold:3+"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPaginateTiers_PageHeight(t *testing.T) {
+	settings := pagingTestSettings()
+	settings.MaxPageHeight = 300
+	tiers := []TierGroup{namedTier("Gold", 20), namedTier("Silver", 30)}
+	pages := paginateTiers(tiers, settings)
+	total := 0
+	for i, page := range pages {
+		if h := buildLayout(page, settings).Height; h > settings.MaxPageHeight {
+			t.Errorf("page %d is %d tall", i+1, h)
+		}
+		for _, tier := range page {
+			total += len(tier.Patrons)
+		}
+	}
+	if total != 50 {
+		t.Errorf("expected every name on a page, got %d", total)
+	}
+}
+
+func TestExportTiersSVG_Pages(t *testing.T) {
+	dir := t.TempDir()
+	settings := pagingTestSettings()
+	settings.MaxNamesPerPage = 10
+	paths, err := ExportTiersSVG([]TierGroup{namedTier("Gold", 15)}, filepath.Join(dir, "all_names.svg"), settings)
+	if err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != "all_names_001.svg" || filepath.Base(paths[1]) != "all_names_002.svg" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	data, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "Gold (continued)") {
+		t.Error("expected the heading to be repeated on the second page")
+	}
+	if !strings.Contains(content, `page="2" pages="2"`) {
+		t.Error("expected page numbers in the metadata")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "all_names.svg")); err == nil {
+		t.Error("did not expect an unnumbered SVG")
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Money is an exact amount in the minor unit of its currency (cents for USD,
+// whole yen for JPY) so totals never pick up float rounding errors.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// currencyExponents lists currencies whose minor unit is not 1/100.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// currencyExponent returns the number of decimal places used by a currency.
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return exp
+	}
+	return 2
+}
+
+// parseMoney parses an amount such as "5", "5.00", "$1,234.50" or "1.234,56"
+// in the given currency. See decimalParts for how the separators are told apart.
+func parseMoney(s string, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	clean := strings.Map(func(r rune) rune {
+		switch r {
+		case '$', '€', '£', '¥', ' ':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if clean == "" {
+		return Money{Currency: currency}, nil
+	}
+	negative := strings.HasPrefix(clean, "-")
+	clean = strings.TrimPrefix(clean, "-")
+
+	exp := currencyExponent(currency)
+	whole, frac, err := decimalParts(clean, exp)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %q: %v", s, err)
+	}
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", s, exp, currency)
+		}
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// decimalParts splits an amount into its whole and fractional digits. When
+// both "," and "." appear, the last one is the decimal separator and the other
+// groups thousands. See singleSeparator for amounts with only one of them.
+func decimalParts(clean string, exp int) (string, string, error) {
+	comma, dot := strings.LastIndex(clean, ","), strings.LastIndex(clean, ".")
+	switch {
+	case comma < 0 && dot < 0:
+		return clean, "", nil
+	case comma < 0:
+		return singleSeparator(clean, ".", exp)
+	case dot < 0:
+		return singleSeparator(clean, ",", exp)
+	case dot > comma:
+		return strings.ReplaceAll(clean[:dot], ",", ""), clean[dot+1:], nil
+	}
+	return strings.ReplaceAll(clean[:comma], ".", ""), clean[comma+1:], nil
+}
+
+// singleSeparator handles amounts using only sep, which may be the decimal
+// separator ("5,00", "5.00") or group thousands ("1,234", "1.234.567"). Groups
+// of exactly three digits mean thousands, except that with a three-decimal
+// currency a single group ("1.250") could be either, so it is rejected rather
+// than guessed.
+func singleSeparator(clean, sep string, exp int) (string, string, error) {
+	groups := strings.Split(clean, sep)
+	grouped := true
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			grouped = false
+		}
+	}
+	switch {
+	case grouped && len(groups) == 2 && exp == 3:
+		return "", "", fmt.Errorf("%q could use %q for thousands or decimals", clean, sep)
+	case grouped:
+		return strings.Join(groups, ""), "", nil
+	case len(groups) == 2:
+		return groups[0], groups[1], nil
+	}
+	return "", "", fmt.Errorf("unexpected %q in %q", sep, clean)
+}
+
+// Float returns the amount in major units, for comparisons and display only.
+func (m Money) Float() float64 {
+	f := float64(m.Minor)
+	for i := 0; i < currencyExponent(m.Currency); i++ {
+		f /= 10
+	}
+	return f
+}
+
+func (m Money) String() string {
+	exp := currencyExponent(m.Currency)
+	s := strconv.FormatFloat(m.Float(), 'f', exp, 64)
+	if m.Currency == "" {
+		return s
+	}
+	return s + " " + m.Currency
+}
+
+// patreonDateLayouts are the date formats seen in Patreon exports over time.
+var patreonDateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006 15:04",
+	"01/02/2006",
+	"1/2/2006 15:04",
+	"1/2/2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// parsePatreonDate parses any date layout Patreon has used. An empty string
+// yields the zero time. Dates without a zone are taken to be UTC.
+func parsePatreonDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range patreonDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
+
+// PatronStatus is the membership state Patreon reports for a patron.
+type PatronStatus int
+
+const (
+	PatronStatusUnknown PatronStatus = iota
+	PatronStatusActive
+	PatronStatusDeclined
+	PatronStatusFormer
+)
+
+var patronStatusNames = map[PatronStatus]string{
+	PatronStatusUnknown:  "",
+	PatronStatusActive:   "Active patron",
+	PatronStatusDeclined: "Declined patron",
+	PatronStatusFormer:   "Former patron",
+}
+
+func (s PatronStatus) String() string { return patronStatusNames[s] }
+
+func parsePatronStatus(s string) (PatronStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return PatronStatusUnknown, nil
+	case "active patron", "active":
+		return PatronStatusActive, nil
+	case "declined patron", "declined":
+		return PatronStatusDeclined, nil
+	case "former patron", "former":
+		return PatronStatusFormer, nil
+	}
+	return PatronStatusUnknown, fmt.Errorf("unknown patron status %q", s)
+}
+
+// ChargeStatus is the outcome of a patron's most recent charge.
+type ChargeStatus int
+
+const (
+	ChargeStatusUnknown ChargeStatus = iota
+	ChargeStatusPaid
+	ChargeStatusDeclined
+	ChargeStatusPending
+	ChargeStatusRefunded
+	ChargeStatusFraud
+)
+
+var chargeStatusNames = map[ChargeStatus]string{
+	ChargeStatusUnknown:  "",
+	ChargeStatusPaid:     "Paid",
+	ChargeStatusDeclined: "Declined",
+	ChargeStatusPending:  "Pending",
+	ChargeStatusRefunded: "Refunded",
+	ChargeStatusFraud:    "Fraud",
+}
+
+func (s ChargeStatus) String() string { return chargeStatusNames[s] }
+
+func parseChargeStatus(s string) (ChargeStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ChargeStatusUnknown, nil
+	case "paid":
+		return ChargeStatusPaid, nil
+	case "declined":
+		return ChargeStatusDeclined, nil
+	case "pending":
+		return ChargeStatusPending, nil
+	case "refunded", "refunded by patreon":
+		return ChargeStatusRefunded, nil
+	case "fraud":
+		return ChargeStatusFraud, nil
+	}
+	return ChargeStatusUnknown, fmt.Errorf("unknown charge status %q", s)
+}
+
+// ChargeFrequency is how often a patron is billed.
+type ChargeFrequency int
+
+const (
+	ChargeFrequencyUnknown ChargeFrequency = iota
+	ChargeFrequencyMonthly
+	ChargeFrequencyAnnual
+	ChargeFrequencyPerCreation
+)
+
+var chargeFrequencyNames = map[ChargeFrequency]string{
+	ChargeFrequencyUnknown:     "",
+	ChargeFrequencyMonthly:     "monthly",
+	ChargeFrequencyAnnual:      "annual",
+	ChargeFrequencyPerCreation: "per creation",
+}
+
+func (f ChargeFrequency) String() string { return chargeFrequencyNames[f] }
+
+func parseChargeFrequency(s string) (ChargeFrequency, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ChargeFrequencyUnknown, nil
+	case "monthly", "month":
+		return ChargeFrequencyMonthly, nil
+	case "annual", "annually", "yearly", "year":
+		return ChargeFrequencyAnnual, nil
+	case "per creation", "per post", "per_creation":
+		return ChargeFrequencyPerCreation, nil
+	}
+	return ChargeFrequencyUnknown, fmt.Errorf("unknown charge frequency %q", s)
+}
+
+// PatronValues holds the typed form of a Patron's raw CSV strings.
+type PatronValues struct {
+	Status           PatronStatus
+	ChargeStatus     ChargeStatus
+	ChargeFrequency  ChargeFrequency
+	PledgeAmount     Money
+	LifetimeAmount   Money
+	PatronageSince   time.Time
+	LastChargeDate   time.Time
+	NextChargeDate   time.Time
+	AccessExpiration time.Time
+	LastUpdated      time.Time
+}
+
+// FieldError records a value in a row that could not be parsed.
+type FieldError struct {
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Column, e.Err)
+}
+
+// parsePatronValues converts the raw strings of a Patron. Every field is
+// attempted; fields that fail keep their zero value and are reported.
+func parsePatronValues(p Patron) (PatronValues, []FieldError) {
+	var v PatronValues
+	var errs []FieldError
+	check := func(column, value string, err error) {
+		if err != nil {
+			errs = append(errs, FieldError{Column: column, Value: value, Err: err})
+		}
+	}
+	var err error
+
+	v.Status, err = parsePatronStatus(p.PatronStatus)
+	check("Patron Status", p.PatronStatus, err)
+	v.ChargeStatus, err = parseChargeStatus(p.LastChargeStatus)
+	check("Last Charge Status", p.LastChargeStatus, err)
+	v.ChargeFrequency, err = parseChargeFrequency(p.ChargeFrequency)
+	check("Charge Frequency", p.ChargeFrequency, err)
+	v.PledgeAmount, err = parseMoney(p.PledgeAmount, p.Currency)
+	check("Pledge Amount", p.PledgeAmount, err)
+	v.LifetimeAmount, err = parseMoney(p.LifetimeAmount, p.Currency)
+	check("Lifetime Amount", p.LifetimeAmount, err)
+	v.PatronageSince, err = parsePatreonDate(p.PatronageSinceDate)
+	check("Patronage Since Date", p.PatronageSinceDate, err)
+	v.LastChargeDate, err = parsePatreonDate(p.LastChargeDate)
+	check("Last Charge Date", p.LastChargeDate, err)
+	v.NextChargeDate, err = parsePatreonDate(p.NextChargeDate)
+	check("Next Charge Date", p.NextChargeDate, err)
+	v.AccessExpiration, err = parsePatreonDate(p.AccessExpiration)
+	check("Access Expiration", p.AccessExpiration, err)
+	v.LastUpdated, err = parsePatreonDate(p.LastUpdated)
+	check("Last Updated", p.LastUpdated, err)
+
+	return v, errs
+}
+
+// Typed returns the parsed values of the patron, parsing them on demand for
+// patrons that were not produced by parsePatrons.
+func (p Patron) Typed() PatronValues {
+	if p.Values != nil {
+		return *p.Values
+	}
+	v, _ := parsePatronValues(p)
+	return v
+}
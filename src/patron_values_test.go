@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in       string
+		currency string
+		want     int64
+	}{
+		{"5", "USD", 500},
+		{"5.00", "USD", 500},
+		{"$1,234.5", "usd", 123450},
+		{"", "USD", 0},
+		{"500", "JPY", 500},
+		{"1.25", "KWD", 1250},
+		{"1.2500", "KWD", 1250},
+		{"-2.50", "EUR", -250},
+		{"5,00", "EUR", 500},
+		{"1.234,56", "EUR", 123456},
+		{"€ 1 234,5", "EUR", 123450},
+		{"1,234", "JPY", 1234},
+		{"1,234,567", "USD", 123456700},
+		{"1.000", "EUR", 100000},
+		{"1.200", "EUR", 120000},
+		{"1.234.567", "EUR", 123456700},
+	}
+	for _, c := range cases {
+		got, err := parseMoney(c.in, c.currency)
+		if err != nil {
+			t.Errorf("parseMoney(%q, %q) unexpected error: %v", c.in, c.currency, err)
+			continue
+		}
+		if got.Minor != c.want {
+			t.Errorf("parseMoney(%q, %q) = %d, want %d", c.in, c.currency, got.Minor, c.want)
+		}
+	}
+	if _, err := parseMoney("1.2345", "USD"); err == nil {
+		t.Error("expected error for too many decimal places")
+	}
+	if _, err := parseMoney("1.250", "KWD"); err == nil {
+		t.Error("expected error for a dot that could be either separator")
+	}
+	if _, err := parseMoney("1,234", "KWD"); err == nil {
+		t.Error("expected error for a comma that could be either separator")
+	}
+	if _, err := parseMoney("1,2,3", "USD"); err == nil {
+		t.Error("expected error for misplaced commas")
+	}
+	if _, err := parseMoney("abc", "USD"); err == nil {
+		t.Error("expected error for non-numeric amount")
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	if got := (Money{Minor: 123450, Currency: "USD"}).String(); got != "1234.50 USD" {
+		t.Errorf("got %q", got)
+	}
+	if got := (Money{Minor: 500, Currency: "JPY"}).String(); got != "500 JPY" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParsePatreonDate(t *testing.T) {
+	want := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2023-04-05", "2023-04-05 00:00:00", "2023-04-05T00:00:00Z", "04/05/2023", "4/5/2023", "Apr 5, 2023"} {
+		got, err := parsePatreonDate(s)
+		if err != nil {
+			t.Errorf("parsePatreonDate(%q) unexpected error: %v", s, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("parsePatreonDate(%q) = %v, want %v", s, got, want)
+		}
+	}
+	if got, err := parsePatreonDate(""); err != nil || !got.IsZero() {
+		t.Errorf("expected zero time for empty string, got %v, %v", got, err)
+	}
+	if _, err := parsePatreonDate("yesterday"); err == nil {
+		t.Error("expected error for unrecognised date")
+	}
+}
+
+func TestParsePatronValues_CollectsErrors(t *testing.T) {
+	p := Patron{
+		PatronStatus:     "Active patron",
+		LastChargeStatus: "Paid",
+		ChargeFrequency:  "annual",
+		PledgeAmount:     "60.00",
+		Currency:         "USD",
+		LastChargeDate:   "not a date",
+		NextChargeDate:   "2024-13-45",
+	}
+	v, errs := parsePatronValues(p)
+	if v.Status != PatronStatusActive || v.ChargeStatus != ChargeStatusPaid || v.ChargeFrequency != ChargeFrequencyAnnual {
+		t.Errorf("unexpected enums: %+v", v)
+	}
+	if v.PledgeAmount.Minor != 6000 || v.PledgeAmount.Currency != "USD" {
+		t.Errorf("unexpected pledge: %+v", v.PledgeAmount)
+	}
+	if len(errs) != 2 || errs[0].Column != "Last Charge Date" || errs[1].Column != "Next Charge Date" {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ExportTiersPNG draws the same layout as ExportTiersSVG into PNG images, using
+// the font in SVG_FONT_FILE. Pages are numbered the same way as the SVG's.
+// It returns the paths written.
+func ExportTiersPNG(tiers []TierGroup, outputPath string, settings Settings) ([]string, error) {
+	font, err := outlineFont(settings)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := layoutPages(tiers, settings)
+	if err != nil {
+		return nil, err
+	}
+	background, err := parseColor(settings.PNGBackground)
+	if err != nil {
+		return nil, fmt.Errorf("PNG_BACKGROUND: %v", err)
+	}
+	paths := pages.paths(outputPath)
+	for i, layout := range pages.Layouts {
+		sprites, err := rasterizeLayout(layout, font, pages.Settings)
+		if err != nil {
+			return paths[:i], err
+		}
+		img := composeSprites(sprites, scaled(layout.Width, settings.PNGScale), scaled(layout.Height, settings.PNGScale), 0, background)
+		if err := writePNGFile(img, paths[i], settings); err != nil {
+			return paths[:i], err
+		}
+	}
+	return paths, nil
+}
+
+// outlineFont loads SVG_FONT_FILE for drawing. Only fonts with TrueType
+// outlines can be drawn.
+func outlineFont(settings Settings) (*sfntFont, error) {
+	if settings.FontFile == "" {
+		return nil, fmt.Errorf("PNG export needs SVG_FONT_FILE to be set to a .ttf font")
+	}
+	font, err := loadFontCached(settings.FontFile)
+	if err != nil {
+		return nil, err
+	}
+	if !font.hasOutlines() {
+		return nil, fmt.Errorf("%s has no TrueType outlines; use a .ttf font", settings.FontFile)
+	}
+	return font, nil
+}
+
+// writePNGFile encodes img with its resolution in a pHYs chunk and the
+// reference date, when there is one, in a "reference-date" tEXt chunk.
+func writePNGFile(img image.Image, outputPath string, settings Settings) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := insertPNGChunk(buf.Bytes(), "pHYs", pngPhys(pngDPI(settings)))
+	if settings.AsOfDate != "" {
+		data = insertPNGChunk(data, "tEXt", []byte("reference-date\x00"+settings.AsOfDate))
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
+
+// pngDPI is PNG_DPI, or 96 DPI (the SVG's CSS pixels) times PNG_SCALE.
+func pngDPI(settings Settings) float64 {
+	if settings.PNGDPI > 0 {
+		return float64(settings.PNGDPI)
+	}
+	return 96 * settings.PNGScale
+}
+
+// pngPhys is the body of a pHYs chunk: pixels per metre in x and y, then
+// unit 1 (metres).
+func pngPhys(dpi float64) []byte {
+	ppm := uint32(math.Round(dpi / 0.0254))
+	body := make([]byte, 9)
+	binary.BigEndian.PutUint32(body, ppm)
+	binary.BigEndian.PutUint32(body[4:], ppm)
+	body[8] = 1
+	return body
+}
+
+// pngHeaderSize is the PNG signature plus the IHDR chunk, which must come
+// first; pHYs and text chunks can go anywhere between it and the image data.
+const pngHeaderSize = 8 + 4 + 4 + 13 + 4
+
+// insertPNGChunk adds a chunk right after the IHDR chunk of an encoded PNG.
+func insertPNGChunk(data []byte, kind string, payload []byte) []byte {
+	body := append([]byte(kind), payload...)
+	chunk := make([]byte, 4+len(body)+4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(body)-4))
+	copy(chunk[4:], body)
+	binary.BigEndian.PutUint32(chunk[4+len(body):], crc32.ChecksumIEEE(body))
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:pngHeaderSize]...)
+	out = append(out, chunk...)
+	return append(out, data[pngHeaderSize:]...)
+}
+
+// textSprite is one layout item drawn once, ready to be placed on any image.
+// Origin is the top-left corner of the masks in scaled layout pixels.
+type textSprite struct {
+	Fill    *image.Alpha
+	Outline *image.Alpha
+	Origin  image.Point
+	Color   color.Color
+}
+
+// rasterizeLayout draws every item of layout at PNG_SCALE. Drawing each name
+// once lets a scrolling sequence reuse the sprites for every frame.
+func rasterizeLayout(layout creditsLayout, font *sfntFont, settings Settings) ([]textSprite, error) {
+	colors := map[string]color.Color{}
+	sprites := make([]textSprite, 0, len(layout.Items))
+	for _, item := range layout.Items {
+		c, ok := colors[item.Color]
+		if !ok {
+			parsed, err := parseColor(item.Color)
+			if err != nil {
+				return nil, fmt.Errorf("color for %q: %v", item.Text, err)
+			}
+			c = parsed
+			colors[item.Color] = c
+		}
+		sprite, err := rasterizeText(item, font, settings.PNGScale)
+		if err != nil {
+			return nil, fmt.Errorf("drawing %q: %v", item.Text, err)
+		}
+		sprite.Color = c
+		sprites = append(sprites, sprite)
+	}
+	return sprites, nil
+}
+
+// rasterizeText draws one item centred on item.X with its baseline on item.Y,
+// like SVG's text-anchor="middle", plus the black outline behind it.
+func rasterizeText(item layoutItem, font *sfntFont, scale float64) (textSprite, error) {
+	size := float64(item.FontSize) * scale
+	k := size / float64(font.unitsPerEm)
+	width := font.textWidth(item.Text, size)
+	left := float64(item.X)*scale - width/2
+	baseline := float64(item.Y) * scale
+	radius := outlineWidth / 2 * scale // half of the stroke lies outside the glyph
+	pad := int(math.Ceil(radius)) + 1
+
+	// Leave room for glyphs that reach past their advance, such as italics.
+	x0 := int(math.Floor(left-size/4)) - pad
+	x1 := int(math.Ceil(left+width+size/4)) + pad
+	y0 := int(math.Floor(baseline-float64(font.ascent)*k)) - pad
+	y1 := int(math.Ceil(baseline-float64(font.descent)*k)) + pad
+
+	r := newRasterizer(x1-x0, y1-y0)
+	pen := left
+	for _, ch := range item.Text {
+		g := font.glyphIndex(ch)
+		contours, err := font.glyphContours(g)
+		if err != nil {
+			return textSprite{}, err
+		}
+		origin := pen
+		transform := func(p glyphPoint) rasterPoint {
+			return rasterPoint{X: origin + p.X*k - float64(x0), Y: baseline - p.Y*k - float64(y0)}
+		}
+		for _, c := range contours {
+			r.contour(c, transform)
+		}
+		pen += float64(font.advance(g)) * k
+	}
+	fill := r.mask()
+	return textSprite{Fill: fill, Outline: dilate(fill, radius), Origin: image.Pt(x0, y0)}, nil
+}
+
+// composeSprites draws the sprites onto a width x height image showing the
+// layout from top (in scaled pixels) downwards.
+func composeSprites(sprites []textSprite, width, height, top int, background color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if _, _, _, a := background.RGBA(); a > 0 {
+		draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	}
+	black := image.NewUniform(color.Black)
+	for _, s := range sprites {
+		rect := s.Fill.Bounds().Add(s.Origin.Sub(image.Pt(0, top)))
+		if !rect.Overlaps(img.Bounds()) {
+			continue
+		}
+		draw.DrawMask(img, rect, black, image.Point{}, s.Outline, image.Point{}, draw.Over)
+		draw.DrawMask(img, rect, image.NewUniform(s.Color), image.Point{}, s.Fill, image.Point{}, draw.Over)
+	}
+	return img
+}
+
+func scaled(v int, scale float64) int {
+	return int(math.Round(float64(v) * scale))
+}
+
+// namedColors are the colour names accepted besides hex values.
+var namedColors = map[string]color.NRGBA{
+	"transparent": {},
+	"none":        {},
+	"black":       {0, 0, 0, 255},
+	"white":       {255, 255, 255, 255},
+	"red":         {255, 0, 0, 255},
+	"green":       {0, 128, 0, 255},
+	"blue":        {0, 0, 255, 255},
+	"yellow":      {255, 255, 0, 255},
+	"gold":        {255, 215, 0, 255},
+	"silver":      {192, 192, 192, 255},
+	"gray":        {128, 128, 128, 255},
+	"grey":        {128, 128, 128, 255},
+	"orange":      {255, 165, 0, 255},
+	"purple":      {128, 0, 128, 255},
+	"pink":        {255, 192, 203, 255},
+}
+
+// parseColor reads a #rgb or #rrggbb colour, or one of namedColors.
+func parseColor(s string) (color.NRGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 || !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, fmt.Errorf("unknown color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("unknown color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
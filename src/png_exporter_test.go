@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRasterizer_Coverage(t *testing.T) {
+	r := newRasterizer(10, 10)
+	square := []glyphPoint{{2, 2, true}, {2, 6, true}, {6.5, 6, true}, {6.5, 2, true}}
+	r.contour(square, func(p glyphPoint) rasterPoint { return rasterPoint{X: p.X, Y: p.Y} })
+	m := r.mask()
+	for _, tc := range []struct {
+		x, y int
+		want uint8
+	}{
+		{3, 3, 255}, // inside
+		{1, 3, 0},   // left of the square
+		{3, 7, 0},   // below it
+		{6, 3, 128}, // half covered by the right edge
+	} {
+		if got := m.AlphaAt(tc.x, tc.y).A; got != tc.want {
+			t.Errorf("coverage at (%d,%d) = %d, want %d", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	outline := dilate(m, 1)
+	if outline.AlphaAt(1, 3).A != 255 || outline.AlphaAt(0, 3).A != 0 {
+		t.Errorf("expected the outline to grow the square by one pixel")
+	}
+}
+
+func pngTestSettings(t *testing.T) Settings {
+	fontPath := filepath.Join(t.TempDir(), "test.ttf")
+	if err := os.WriteFile(fontPath, buildSFNT(testFontTables()), 0644); err != nil {
+		t.Fatalf("failed to write font: %v", err)
+	}
+	settings := pagingTestSettings()
+	settings.SVGLayout = "flat"
+	settings.FontFile = fontPath
+	settings.ExportPNG = true
+	settings.RandomizeSVGColors = false
+	settings.ColumnColors = []string{"#ff0000"}
+	settings.Width = 100
+	settings.FontSize = 50
+	settings.LineHeight = 60
+	return settings
+}
+
+func TestExportTiersPNG(t *testing.T) {
+	settings := pngTestSettings(t)
+	settings.PNGScale = 2
+	settings.AsOfDate = "2024-05-01T00:00:00Z"
+	path := filepath.Join(t.TempDir(), "all_names.png")
+	tiers := []TierGroup{{Name: "Gold", Patrons: []Patron{{Name: "A"}}}}
+	if _, err := ExportTiersPNG(tiers, path, settings); err != nil {
+		t.Fatalf("ExportTiersPNG failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	// 100 x (10 + 60 + 10) at scale 2.
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 160 {
+		t.Fatalf("unexpected size %v", b)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("tEXtreference-date\x002024-05-01T00:00:00Z")) {
+		t.Error("expected a reference-date tEXt chunk")
+	}
+	// 96 DPI times PNG_SCALE 2 is 192 DPI, 7559 pixels per metre.
+	if !bytes.Contains(data, append([]byte("pHYs"), 0, 0, 0x1d, 0x87, 0, 0, 0x1d, 0x87, 1)) {
+		t.Error("expected a 192 DPI pHYs chunk")
+	}
+
+	settings.PNGDPI = 300
+	if _, err := ExportTiersPNG(tiers, path, settings); err != nil {
+		t.Fatalf("ExportTiersPNG failed: %v", err)
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	// 300 DPI is 11811 pixels per metre.
+	if !bytes.Contains(data, append([]byte("pHYs"), 0, 0, 0x2e, 0x23, 0, 0, 0x2e, 0x23, 1)) {
+		t.Error("expected PNG_DPI=300 in the pHYs chunk")
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("PNG with pHYs and tEXt chunks does not decode: %v", err)
+	}
+
+	// 'A' is a 600 unit advance centred on x=50 with a box from 100 to 500
+	// units. At scale 2 (100px per em) the box spans x 80..120 and y 50..120.
+	at := func(x, y int) color.NRGBA { return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA) }
+	if c := at(100, 100); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected red inside the glyph, got %v", c)
+	}
+	if c := at(79, 100); c != (color.NRGBA{0, 0, 0, 255}) {
+		t.Errorf("expected the black outline just outside the glyph, got %v", c)
+	}
+	if c := at(76, 100); c.A != 0 {
+		t.Errorf("expected the outline to be 2px wide at scale 2, got %v", c)
+	}
+	if c := at(10, 10); c.A != 0 {
+		t.Errorf("expected a transparent background, got %v", c)
+	}
+}
+
+func TestExportTiersPNG_Background(t *testing.T) {
+	settings := pngTestSettings(t)
+	settings.PNGBackground = "#0000ff"
+	settings.MaxNamesPerPage = 1
+	dir := t.TempDir()
+	tiers := []TierGroup{{Name: "Gold", Patrons: []Patron{{Name: "A"}, {Name: "B"}}}}
+	paths, err := ExportTiersPNG(tiers, filepath.Join(dir, "all_names.png"), settings)
+	if err != nil {
+		t.Fatalf("ExportTiersPNG failed: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[1]) != "all_names_002.png" {
+		t.Fatalf("unexpected pages: %v", paths)
+	}
+	f, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, a := img.At(0, 0).RGBA(); r != 0 || g != 0 || b != 0xffff || a != 0xffff {
+		t.Errorf("expected a blue background, got %v", img.At(0, 0))
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for in, want := range map[string]color.NRGBA{
+		"#FF8800":     {255, 136, 0, 255},
+		"#f80":        {255, 136, 0, 255},
+		"white":       {255, 255, 255, 255},
+		"transparent": {},
+	} {
+		got, err := parseColor(in)
+		if err != nil || got != want {
+			t.Errorf("parseColor(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "ff8800", "#12345", "#gggggg", "chartreuse-ish"} {
+		if _, err := parseColor(in); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestValidate_PNG(t *testing.T) {
+	settings := pngTestSettings(t)
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	settings.PNGDPI = -1
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "PNG_DPI") {
+		t.Errorf("expected a PNG_DPI error, got %v", err)
+	}
+	settings.PNGDPI = 0
+	settings.FontFile = ""
+	if err := settings.Validate(); err == nil || !strings.Contains(err.Error(), "SVG_FONT_FILE") {
+		t.Errorf("expected a font error, got %v", err)
+	}
+}
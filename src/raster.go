@@ -0,0 +1,231 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// rasterizer turns closed outlines into an anti-aliased coverage mask. Each
+// edge adds the area it covers to an accumulation buffer; summing a row from
+// left to right then gives the coverage of every pixel. Overlapping contours
+// of the same direction are clamped rather than counted twice.
+type rasterizer struct {
+	w, h   int
+	stride int // w plus room for edges that end on the right border
+	acc    []float32
+}
+
+func newRasterizer(w, h int) *rasterizer {
+	stride := w + 2
+	return &rasterizer{w: w, h: h, stride: stride, acc: make([]float32, stride*h)}
+}
+
+type rasterPoint struct{ X, Y float64 }
+
+// line adds the edge from p0 to p1. Points left or right of the mask are
+// clamped to its border, which leaves the coverage inside unchanged.
+func (r *rasterizer) line(p0, p1 rasterPoint) {
+	if p0.Y == p1.Y {
+		return
+	}
+	p0.X = clampFloat(p0.X, 0, float64(r.w))
+	p1.X = clampFloat(p1.X, 0, float64(r.w))
+	dir := float32(1)
+	if p0.Y > p1.Y {
+		dir = -1
+		p0, p1 = p1, p0
+	}
+	dxdy := (p1.X - p0.X) / (p1.Y - p0.Y)
+	x := p0.X
+	yStart := int(math.Floor(p0.Y))
+	if yStart < 0 {
+		x -= p0.Y * dxdy
+		yStart = 0
+	}
+	yEnd := int(math.Ceil(p1.Y))
+	if yEnd > r.h {
+		yEnd = r.h
+	}
+	for y := yStart; y < yEnd; y++ {
+		row := r.acc[y*r.stride : (y+1)*r.stride]
+		dy := math.Min(float64(y+1), p1.Y) - math.Max(float64(y), p0.Y)
+		xNext := x + dxdy*dy
+		d := float32(dy) * dir
+		x0, x1 := x, xNext
+		if x0 > x1 {
+			x0, x1 = x1, x0
+		}
+		x0Floor := math.Floor(x0)
+		x0i := int(x0Floor)
+		x1Ceil := math.Ceil(x1)
+		x1i := int(x1Ceil)
+		if x1i <= x0i+1 {
+			// The edge stays within one pixel column on this row.
+			xmf := float32(0.5*(x+xNext) - x0Floor)
+			row[x0i] += d - d*xmf
+			row[x0i+1] += d * xmf
+		} else {
+			s := float32(1 / (x1 - x0))
+			x0f := float32(x0 - x0Floor)
+			a0 := 0.5 * s * (1 - x0f) * (1 - x0f)
+			x1f := float32(x1 - x1Ceil + 1)
+			am := 0.5 * s * x1f * x1f
+			row[x0i] += d * a0
+			if x1i == x0i+2 {
+				row[x0i+1] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - x0f)
+				row[x0i+1] += d * (a1 - a0)
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					row[xi] += d * s
+				}
+				a2 := a1 + float32(x1i-x0i-3)*s
+				row[x1i-1] += d * (1 - a2 - am)
+			}
+			row[x1i] += d * am
+		}
+		x = xNext
+	}
+}
+
+// quad adds a quadratic Bézier curve, split into enough lines that the
+// error stays well below a pixel.
+func (r *rasterizer) quad(p0, c, p1 rasterPoint) {
+	dev := math.Hypot(p0.X-2*c.X+p1.X, p0.Y-2*c.Y+p1.Y)
+	n := int(math.Ceil(math.Sqrt(dev * 2)))
+	if n < 1 {
+		n = 1
+	}
+	prev := p0
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		p := rasterPoint{
+			X: u*u*p0.X + 2*u*t*c.X + t*t*p1.X,
+			Y: u*u*p0.Y + 2*u*t*c.Y + t*t*p1.Y,
+		}
+		r.line(prev, p)
+		prev = p
+	}
+}
+
+// contour adds a closed TrueType contour, mapping font units to pixels with
+// transform. Two control points in a row imply an on-curve point between them.
+func (r *rasterizer) contour(points []glyphPoint, transform func(glyphPoint) rasterPoint) {
+	n := len(points)
+	if n == 0 {
+		return
+	}
+	// Start on an on-curve point, or the midpoint of two control points.
+	first := -1
+	for i, p := range points {
+		if p.OnCurve {
+			first = i
+			break
+		}
+	}
+	var start rasterPoint
+	if first >= 0 {
+		start = transform(points[first])
+	} else {
+		start = midpoint(transform(points[0]), transform(points[n-1]))
+		// Every point is a control point; begin just before the first one.
+		first = n - 1
+	}
+	current := start
+	var control *rasterPoint
+	for k := 1; k <= n; k++ {
+		p := points[(first+k)%n]
+		tp := transform(p)
+		if p.OnCurve {
+			if control != nil {
+				r.quad(current, *control, tp)
+				control = nil
+			} else {
+				r.line(current, tp)
+			}
+			current = tp
+			continue
+		}
+		if control != nil {
+			mid := midpoint(*control, tp)
+			r.quad(current, *control, mid)
+			current = mid
+		}
+		c := tp
+		control = &c
+	}
+	if control != nil {
+		r.quad(current, *control, start)
+	} else {
+		r.line(current, start)
+	}
+}
+
+// mask returns the accumulated coverage as an alpha mask.
+func (r *rasterizer) mask() *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, r.w, r.h))
+	for y := 0; y < r.h; y++ {
+		row := r.acc[y*r.stride : (y+1)*r.stride]
+		sum := float32(0)
+		for x := 0; x < r.w; x++ {
+			sum += row[x]
+			a := sum
+			if a < 0 {
+				a = -a
+			}
+			if a > 1 {
+				a = 1
+			}
+			m.Pix[y*m.Stride+x] = uint8(a*255 + 0.5)
+		}
+	}
+	return m
+}
+
+// dilate grows a mask by radius pixels in every direction, which is how the
+// outline around each name is drawn.
+func dilate(m *image.Alpha, radius float64) *image.Alpha {
+	b := m.Bounds()
+	out := image.NewAlpha(b)
+	reach := int(math.Ceil(radius))
+	type offset struct{ dx, dy int }
+	var disk []offset
+	for dy := -reach; dy <= reach; dy++ {
+		for dx := -reach; dx <= reach; dx++ {
+			if float64(dx*dx+dy*dy) <= radius*radius+0.5 {
+				disk = append(disk, offset{dx, dy})
+			}
+		}
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			best := uint8(0)
+			for _, o := range disk {
+				sx, sy := x+o.dx, y+o.dy
+				if sx < b.Min.X || sy < b.Min.Y || sx >= b.Max.X || sy >= b.Max.Y {
+					continue
+				}
+				if a := m.Pix[(sy-b.Min.Y)*m.Stride+sx-b.Min.X]; a > best {
+					best = a
+				}
+			}
+			out.Pix[(y-b.Min.Y)*out.Stride+x-b.Min.X] = best
+		}
+	}
+	return out
+}
+
+func midpoint(a, b rasterPoint) rasterPoint {
+	return rasterPoint{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
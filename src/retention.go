@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// datedRoster is the paying patrons of one export, as of its reference date.
+type datedRoster struct {
+	Date    time.Time
+	Patrons []Patron
+}
+
+// rosterMonth is the last roster seen in a calendar month, keyed like
+// snapshots (user ID, falling back to email).
+type rosterMonth struct {
+	Month  int // monthIndex
+	Paying map[string]Patron
+}
+
+// monthIndex counts calendar months, so consecutive months differ by one.
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+func monthLabel(index int) string {
+	return fmt.Sprintf("%04d-%02d", index/12, index%12+1)
+}
+
+// rosterMonths keeps the newest roster of each month, oldest month first.
+func rosterMonths(rosters []datedRoster) []rosterMonth {
+	sort.SliceStable(rosters, func(i, j int) bool { return rosters[i].Date.Before(rosters[j].Date) })
+	var months []rosterMonth
+	for _, r := range rosters {
+		m := rosterMonth{Month: monthIndex(r.Date), Paying: make(map[string]Patron, len(r.Patrons))}
+		for _, p := range r.Patrons {
+			m.Paying[snapshotKey(p)] = p
+		}
+		if n := len(months); n > 0 && months[n-1].Month == m.Month {
+			months[n-1] = m
+			continue
+		}
+		months = append(months, m)
+	}
+	return months
+}
+
+// cohortRow is how many of the patrons who started in one month were still
+// paying N months later. Retained[N] is -1 for months without an export.
+type cohortRow struct {
+	Month    int
+	Size     int
+	Retained []int
+}
+
+// churnRow compares the rosters of two consecutive exports. They are usually a
+// month apart, but a missing export makes From and To further apart, and the
+// row then covers all the months between them.
+type churnRow struct {
+	From        int // month of the previous export
+	To          int
+	Start       int // paying in the previous export
+	End         int
+	New         int
+	Reactivated int
+	Churned     int
+	Rate        float64 // Churned / Start
+}
+
+// reactivation is a patron who came back after missing from an export.
+type reactivation struct {
+	Name     string
+	UserID   string
+	LastSeen int
+	Returned int
+}
+
+// tenureRow is the average number of months patrons of a tier have paying,
+// counting from their Patronage Since month to the last export they were in.
+type tenureRow struct {
+	Tier          string
+	Patrons       int
+	Active        int
+	AverageMonths float64
+}
+
+type retentionReport struct {
+	Months        []int
+	Cohorts       []cohortRow
+	Churn         []churnRow
+	Reactivations []reactivation
+	Tenure        []tenureRow
+}
+
+// patronHistory is what the analysis knows about one patron.
+type patronHistory struct {
+	Start   int // month of Patronage Since, or of the first export they were in
+	Present map[int]bool
+	Latest  Patron
+	Last    int // last month they were in an export
+}
+
+// analyzeRetention builds cohort, churn, reactivation and tenure tables from
+// monthly rosters. Cohorts are by Patronage Since month; only months from the
+// first export on get a cohort, since earlier cohorts would only count the
+// patrons who had not left yet.
+func analyzeRetention(months []rosterMonth, tierOrder []string) retentionReport {
+	var report retentionReport
+	if len(months) == 0 {
+		return report
+	}
+	observed := make(map[int]bool, len(months))
+	for _, m := range months {
+		report.Months = append(report.Months, m.Month)
+		observed[m.Month] = true
+	}
+
+	patrons := make(map[string]*patronHistory)
+	var keys []string
+	for _, m := range months {
+		for key, p := range m.Paying {
+			h, ok := patrons[key]
+			if !ok {
+				h = &patronHistory{Start: m.Month, Present: make(map[int]bool)}
+				patrons[key] = h
+				keys = append(keys, key)
+			}
+			if since := p.Typed().PatronageSince; !since.IsZero() && monthIndex(since) < h.Start {
+				h.Start = monthIndex(since)
+			}
+			h.Present[m.Month] = true
+			h.Latest = p
+			h.Last = m.Month
+		}
+	}
+	sort.Strings(keys)
+
+	// Cohorts.
+	first, last := months[0].Month, months[len(months)-1].Month
+	cohorts := make(map[int]*cohortRow)
+	for _, key := range keys {
+		h := patrons[key]
+		if h.Start < first {
+			continue
+		}
+		row, ok := cohorts[h.Start]
+		if !ok {
+			row = &cohortRow{Month: h.Start, Retained: make([]int, last-h.Start+1)}
+			for n := range row.Retained {
+				if !observed[h.Start+n] {
+					row.Retained[n] = -1
+				}
+			}
+			cohorts[h.Start] = row
+		}
+		row.Size++
+		for n := range row.Retained {
+			if h.Present[h.Start+n] {
+				row.Retained[n]++
+			}
+		}
+	}
+	for _, row := range cohorts {
+		report.Cohorts = append(report.Cohorts, *row)
+	}
+	sort.Slice(report.Cohorts, func(i, j int) bool { return report.Cohorts[i].Month < report.Cohorts[j].Month })
+
+	// Churn and reactivations between consecutive exports.
+	seen := make(map[string]int) // last month each patron was in an export
+	for key := range months[0].Paying {
+		seen[key] = months[0].Month
+	}
+	for i := 1; i < len(months); i++ {
+		prev, cur := months[i-1], months[i]
+		row := churnRow{From: prev.Month, To: cur.Month, Start: len(prev.Paying), End: len(cur.Paying)}
+		for key := range prev.Paying {
+			if _, ok := cur.Paying[key]; !ok {
+				row.Churned++
+			}
+		}
+		for _, key := range keys {
+			p, ok := cur.Paying[key]
+			if !ok {
+				continue
+			}
+			if _, stayed := prev.Paying[key]; !stayed {
+				if lastSeen, ok := seen[key]; ok {
+					row.Reactivated++
+					report.Reactivations = append(report.Reactivations, reactivation{
+						Name: p.Name, UserID: strings.TrimSpace(p.UserID), LastSeen: lastSeen, Returned: cur.Month})
+				} else {
+					row.New++
+				}
+			}
+			seen[key] = cur.Month
+		}
+		if row.Start > 0 {
+			row.Rate = float64(row.Churned) / float64(row.Start)
+		}
+		report.Churn = append(report.Churn, row)
+	}
+
+	// Tenure by the tier of each patron's latest export.
+	var latest []Patron
+	tierKeys := make(map[string][]string)
+	for _, key := range keys {
+		p := patrons[key].Latest
+		latest = append(latest, p)
+		tier := strings.TrimSpace(p.Tier)
+		tierKeys[tier] = append(tierKeys[tier], key)
+	}
+	for _, t := range orderTiers(groupAndSortByTier(latest), tierOrder) {
+		row := tenureRow{Tier: t.Name, Patrons: len(tierKeys[t.Name])}
+		total := 0
+		for _, key := range tierKeys[t.Name] {
+			h := patrons[key]
+			total += h.Last - h.Start + 1
+			if h.Last == last {
+				row.Active++
+			}
+		}
+		row.AverageMonths = float64(total) / float64(row.Patrons)
+		report.Tenure = append(report.Tenure, row)
+	}
+	return report
+}
+
+// writeRetentionCSVs writes the four tables to dir as retention_*.csv and
+// returns the files written.
+func writeRetentionCSVs(dir string, r retentionReport) ([]string, error) {
+	maxN := 0
+	for _, c := range r.Cohorts {
+		if len(c.Retained) > maxN {
+			maxN = len(c.Retained)
+		}
+	}
+	cohorts := [][]string{{"cohort", "patrons"}}
+	for n := 0; n < maxN; n++ {
+		cohorts[0] = append(cohorts[0], "month_"+strconv.Itoa(n))
+	}
+	for _, c := range r.Cohorts {
+		row := []string{monthLabel(c.Month), strconv.Itoa(c.Size)}
+		for n := 0; n < maxN; n++ {
+			cell := ""
+			if n < len(c.Retained) && c.Retained[n] >= 0 {
+				cell = strconv.Itoa(c.Retained[n])
+			}
+			row = append(row, cell)
+		}
+		cohorts = append(cohorts, row)
+	}
+
+	churn := [][]string{{"from_month", "to_month", "paying_before", "paying", "new", "reactivated", "churned", "churn_rate"}}
+	for _, c := range r.Churn {
+		churn = append(churn, []string{monthLabel(c.From), monthLabel(c.To), strconv.Itoa(c.Start), strconv.Itoa(c.End),
+			strconv.Itoa(c.New), strconv.Itoa(c.Reactivated), strconv.Itoa(c.Churned), formatNumber(c.Rate)})
+	}
+
+	reactivations := [][]string{{"name", "user_id", "last_seen", "returned"}}
+	for _, re := range r.Reactivations {
+		reactivations = append(reactivations, []string{re.Name, re.UserID, monthLabel(re.LastSeen), monthLabel(re.Returned)})
+	}
+
+	tenure := [][]string{{"tier", "patrons", "still_paying", "average_tenure_months"}}
+	for _, t := range r.Tenure {
+		tenure = append(tenure, []string{t.Tier, strconv.Itoa(t.Patrons), strconv.Itoa(t.Active), strconv.FormatFloat(t.AverageMonths, 'f', 1, 64)})
+	}
+
+	var paths []string
+	for _, table := range []struct {
+		name string
+		rows [][]string
+	}{
+		{"retention_cohorts.csv", cohorts},
+		{"retention_churn.csv", churn},
+		{"retention_reactivations.csv", reactivations},
+		{"retention_tenure.csv", tenure},
+	} {
+		path := filepath.Join(dir, table.name)
+		if err := writeCSVRows(path, table.rows); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeCSVRows(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	return w.Error()
+}
+
+// Size of the retention chart and of each of its two panels.
+const (
+	chartWidth     = 800
+	chartPanel     = 300
+	chartMargin    = 50
+	chartFontSize  = 12
+	chartTitleSize = 16
+)
+
+// writeRetentionSVG draws the cohort retention curves (share of each cohort
+// still paying N months after starting) above a bar chart of churn between
+// consecutive exports. Bars that span more than one month say how many.
+// Cohorts take the SVG_COLUMN_COLORS in turn.
+func writeRetentionSVG(w io.Writer, r retentionReport, settings Settings) {
+	font := escapeXML(settings.FontFamily)
+	text := func(x, y float64, anchor string, size int, color, s string) {
+		fmt.Fprintf(w, `<text x="%s" y="%s" text-anchor="%s" font-family="%s" font-size="%d" fill="%s">%s</text>`,
+			formatNumber(x), formatNumber(y), anchor, font, size, color, escapeXML(s))
+		fmt.Fprintln(w)
+	}
+	line := func(x1, y1, x2, y2 float64, color string) {
+		fmt.Fprintf(w, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`,
+			formatNumber(x1), formatNumber(y1), formatNumber(x2), formatNumber(y2), color)
+		fmt.Fprintln(w)
+	}
+	plotWidth := float64(chartWidth - chartMargin*2)
+	plotHeight := float64(chartPanel - chartMargin*2)
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, chartWidth, chartPanel*2)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="#111"/>`)
+	fmt.Fprintln(w)
+
+	// Retention curves.
+	top := float64(chartMargin)
+	text(chartWidth/2, top-20, "middle", chartTitleSize, settings.HeadingColor, "Still paying, by month started")
+	maxN := 1
+	for _, c := range r.Cohorts {
+		if len(c.Retained)-1 > maxN {
+			maxN = len(c.Retained) - 1
+		}
+	}
+	x := func(n int) float64 { return chartMargin + plotWidth*float64(n)/float64(maxN) }
+	y := func(share float64) float64 { return top + plotHeight*(1-share) }
+	for _, pct := range []int{0, 50, 100} {
+		line(chartMargin, y(float64(pct)/100), chartMargin+plotWidth, y(float64(pct)/100), "#444")
+		text(chartMargin-6, y(float64(pct)/100)+4, "end", chartFontSize, "#aaa", strconv.Itoa(pct)+"%")
+	}
+	// Label at most about a dozen ticks so long histories stay readable.
+	step := (maxN + 11) / 12
+	for n := 0; n <= maxN; n += step {
+		text(x(n), top+plotHeight+16, "middle", chartFontSize, "#aaa", strconv.Itoa(n))
+	}
+	text(chartWidth/2, top+plotHeight+32, "middle", chartFontSize, "#aaa", "months after starting")
+	for i, c := range r.Cohorts {
+		color := settings.ColumnColors[i%len(settings.ColumnColors)]
+		var points []string
+		lastX, lastY := 0.0, 0.0
+		for n, retained := range c.Retained {
+			if retained < 0 || c.Size == 0 {
+				continue
+			}
+			lastX, lastY = x(n), y(float64(retained)/float64(c.Size))
+			points = append(points, formatNumber(lastX)+","+formatNumber(lastY))
+		}
+		if len(points) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(points, " "), color)
+		fmt.Fprintln(w)
+		text(lastX+4, lastY-4, "start", chartFontSize, color, monthLabel(c.Month))
+	}
+
+	// Churn between exports.
+	top = float64(chartPanel + chartMargin)
+	text(chartWidth/2, top-20, "middle", chartTitleSize, settings.HeadingColor, "Churn between exports")
+	maxRate := 0.1
+	for _, c := range r.Churn {
+		if c.Rate > maxRate {
+			maxRate = c.Rate
+		}
+	}
+	line(chartMargin, top+plotHeight, chartMargin+plotWidth, top+plotHeight, "#444")
+	if len(r.Churn) > 0 {
+		slot := plotWidth / float64(len(r.Churn))
+		step := (len(r.Churn) + 11) / 12
+		color := settings.ColumnColors[0]
+		for i, c := range r.Churn {
+			h := plotHeight * c.Rate / maxRate
+			left := chartMargin + slot*float64(i) + slot*0.15
+			fmt.Fprintf(w, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`,
+				formatNumber(left), formatNumber(top+plotHeight-h), formatNumber(slot*0.7), formatNumber(h), color)
+			fmt.Fprintln(w)
+			center := chartMargin + slot*(float64(i)+0.5)
+			if i%step == 0 {
+				text(center, top+plotHeight-h-4, "middle", chartFontSize, "#fff", strconv.FormatFloat(c.Rate*100, 'f', 1, 64)+"%")
+				text(center, top+plotHeight+16, "middle", chartFontSize, "#aaa", monthLabel(c.To))
+				if months := c.To - c.From; months > 1 {
+					text(center, top+plotHeight+30, "middle", chartFontSize, "#aaa", fmt.Sprintf("(%d months)", months))
+				}
+			}
+		}
+	}
+	fmt.Fprint(w, `</svg>`)
+}
+
+func writeRetentionSVGFile(path string, r retentionReport, settings Settings) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	writeRetentionSVG(bw, r, settings)
+	return bw.Flush()
+}
+
+// retentionRosters loads the paying patrons of each export named in paths,
+// or of every HISTORY_DIR snapshot when there are none, each filtered as of
+// its own reference date.
+func retentionRosters(paths []string, settings Settings, rules []Rule, historyDir string) ([]datedRoster, error) {
+	var rosters []datedRoster
+	if len(paths) > 0 {
+		for _, path := range paths {
+			patrons, asOf, err := loadFilteredExport(path, settings, rules, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			rosters = append(rosters, datedRoster{Date: asOf, Patrons: patrons})
+		}
+		return rosters, nil
+	}
+	if historyDir == "" {
+		return nil, fmt.Errorf("HISTORY_DIR is not set; name the exports to compare instead")
+	}
+	snapshots, err := loadSnapshots(historyDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		asOf, err := parsePatreonDate(s.ReferenceDate)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %v", s.ID, err)
+		}
+		patrons, _, err := parsePatrons(s.records(), &Diagnostics{})
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %v", s.ID, err)
+		}
+		rosters = append(rosters, datedRoster{Date: asOf, Patrons: filterExport(patrons, settings, rules, asOf)})
+	}
+	return rosters, nil
+}
+
+// runRetention implements "retention [-o dir] [export.csv ...]".
+func runRetention(args []string, settings Settings, rules []Rule, historyDir, outputDir string) error {
+	fs := flag.NewFlagSet("retention", flag.ContinueOnError)
+	out := fs.String("o", outputDir, "folder to write the tables and chart to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	rosters, err := retentionRosters(fs.Args(), settings, rules, historyDir)
+	if err != nil {
+		return err
+	}
+	months := rosterMonths(rosters)
+	if len(months) < 2 {
+		return fmt.Errorf("need exports from at least two different months, found %d", len(months))
+	}
+	report := analyzeRetention(months, settings.TierOrder)
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	paths, err := writeRetentionCSVs(*out, report)
+	if err != nil {
+		return err
+	}
+	chart := filepath.Join(*out, "retention.svg")
+	if err := writeRetentionSVGFile(chart, report, settings); err != nil {
+		return err
+	}
+	paths = append(paths, chart)
+
+	fmt.Printf("Retention from %s to %s (%d months with an export)\n",
+		monthLabel(report.Months[0]), monthLabel(report.Months[len(report.Months)-1]), len(report.Months))
+	latest := report.Churn[len(report.Churn)-1]
+	fmt.Printf("Churn from %s to %s: %s%% (%d of %d), %d new, %d reactivated\n", monthLabel(latest.From), monthLabel(latest.To),
+		strconv.FormatFloat(latest.Rate*100, 'f', 1, 64), latest.Churned, latest.Start, latest.New, latest.Reactivated)
+	for _, path := range paths {
+		fmt.Printf("Created %s\n", path)
+	}
+	return nil
+}
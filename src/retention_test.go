@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func retentionTestMonths() []rosterMonth {
+	a := Patron{UserID: "a", Name: "Alice", Tier: "Gold", PatronageSinceDate: "2024-01-05"}
+	b := Patron{UserID: "b", Name: "Bob", Tier: "Silver", PatronageSinceDate: "2023-06-01"}
+	c := Patron{UserID: "c", Name: "Carol", Tier: "Silver", PatronageSinceDate: "2024-01-10"}
+	d := Patron{UserID: "d", Name: "Dan", Tier: "Gold", PatronageSinceDate: "2024-02-03"}
+	date := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02", s)
+		return t
+	}
+	return rosterMonths([]datedRoster{
+		{Date: date("2024-05-15"), Patrons: []Patron{a, c, d}},
+		{Date: date("2024-01-02"), Patrons: []Patron{a}},
+		{Date: date("2024-01-15"), Patrons: []Patron{a, b, c}},
+		{Date: date("2024-02-15"), Patrons: []Patron{a, b, c, d}},
+		{Date: date("2024-03-15"), Patrons: []Patron{a, b, d}},
+	})
+}
+
+func TestAnalyzeRetention(t *testing.T) {
+	months := retentionTestMonths()
+	if len(months) != 4 || len(months[0].Paying) != 3 {
+		t.Fatalf("rosterMonths kept %d months, first with %d patrons; want 4 and the later January export", len(months), len(months[0].Paying))
+	}
+	r := analyzeRetention(months, []string{"Gold", "Silver"})
+
+	wantCohorts := []cohortRow{
+		{Month: monthIndex(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), Size: 2, Retained: []int{2, 2, 1, -1, 2}},
+		{Month: monthIndex(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)), Size: 1, Retained: []int{1, 1, -1, 1}},
+	}
+	if !reflect.DeepEqual(r.Cohorts, wantCohorts) {
+		t.Errorf("cohorts = %+v, want %+v", r.Cohorts, wantCohorts)
+	}
+
+	var churn []string
+	for _, c := range r.Churn {
+		churn = append(churn, strings.Join([]string{monthLabel(c.From), monthLabel(c.To), formatNumber(c.Rate)}, " "))
+		if c.To == monthIndex(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) && (c.New != 0 || c.Reactivated != 1 || c.Churned != 1) {
+			t.Errorf("May = %+v, want Carol back and Bob gone", c)
+		}
+	}
+	if want := []string{"2024-01 2024-02 0", "2024-02 2024-03 0.25", "2024-03 2024-05 0.3333"}; !reflect.DeepEqual(churn, want) {
+		t.Errorf("churn = %v, want %v", churn, want)
+	}
+
+	if len(r.Reactivations) != 1 || r.Reactivations[0].Name != "Carol" || monthLabel(r.Reactivations[0].LastSeen) != "2024-02" {
+		t.Errorf("reactivations = %+v", r.Reactivations)
+	}
+
+	want := []tenureRow{
+		{Tier: "Gold", Patrons: 2, Active: 2, AverageMonths: 4.5},
+		{Tier: "Silver", Patrons: 2, Active: 1, AverageMonths: 7.5},
+	}
+	if !reflect.DeepEqual(r.Tenure, want) {
+		t.Errorf("tenure = %+v, want %+v", r.Tenure, want)
+	}
+}
+
+func TestWriteRetentionOutputs(t *testing.T) {
+	r := analyzeRetention(retentionTestMonths(), nil)
+	dir := t.TempDir()
+	paths, err := writeRetentionCSVs(dir, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("wrote %v", paths)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "retention_cohorts.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cohort,patrons,month_0,month_1,month_2,month_3,month_4\n2024-01,2,2,2,1,,2\n2024-02,1,1,1,,1,\n"
+	if string(data) != want {
+		t.Errorf("retention_cohorts.csv =\n%s\nwant\n%s", data, want)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "retention_churn.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\n2024-03,2024-05,3,3,0,1,1,0.3333\n") {
+		t.Errorf("retention_churn.csv should cover the missing April in one row:\n%s", data)
+	}
+
+	var svg bytes.Buffer
+	writeRetentionSVG(&svg, r, defaultSettings())
+	if n := strings.Count(svg.String(), "<polyline"); n != 2 {
+		t.Errorf("chart has %d cohort lines, want 2", n)
+	}
+	if n := strings.Count(svg.String(), "<rect x="); n != 3 {
+		t.Errorf("chart has %d churn bars, want 3", n)
+	}
+	if !strings.Contains(svg.String(), "(2 months)") {
+		t.Error("expected the March to May bar to say it spans two months")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultRosterFields are exported when ROSTER_FIELDS is not set. They leave
+// out email, Discord, address and phone so personal details are only
+// exported when someone asks for them by name.
+var defaultRosterFields = []string{"name", "tier", "patron_status", "pledge_amount", "currency", "patronage_since_date"}
+
+// runSummary holds the counts printed at the end of a run.
+type runSummary struct {
+	ReferenceDate string `json:"reference_date"`
+	SourceFile    string `json:"source_file"`
+	TotalPaying   int    `json:"total_paying"`
+	FreeTier      int    `json:"free_tier"`
+	ExpiredAccess int    `json:"skipped_expired_access"`
+	Unpaid        int    `json:"skipped_unpaid"`
+	CustomRules   int    `json:"skipped_custom_rules"`
+	Malformed     int    `json:"skipped_malformed"`
+	Unparseable   int    `json:"unparseable_rows"`
+	GracePeriod   int    `json:"grace_period"`
+}
+
+// rosterColumns looks up the patronColumns named by keys, in that order.
+func rosterColumns(keys []string) ([]patronColumn, error) {
+	byKey := make(map[string]patronColumn, len(patronColumns))
+	for _, c := range patronColumns {
+		byKey[c.Key] = c
+	}
+	columns := make([]patronColumn, 0, len(keys))
+	var unknown []string
+	for _, key := range keys {
+		c, ok := byKey[strings.ToLower(key)]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		columns = append(columns, c)
+	}
+	if len(unknown) > 0 {
+		var valid []string
+		for _, c := range patronColumns {
+			valid = append(valid, c.Key)
+		}
+		return nil, fmt.Errorf("unknown field(s) %s; valid fields are %s", strings.Join(unknown, ", "), strings.Join(valid, ", "))
+	}
+	return columns, nil
+}
+
+type rosterTier struct {
+	Name    string              `json:"name"`
+	Count   int                 `json:"count"`
+	Patrons []map[string]string `json:"patrons"`
+}
+
+type rosterFile struct {
+	Fields  []string     `json:"fields"`
+	Summary runSummary   `json:"summary"`
+	Tiers   []rosterTier `json:"tiers"`
+}
+
+// writeRosterJSON writes the filtered patrons by tier, with only the
+// ROSTER_FIELDS of each patron, and the run summary.
+func writeRosterJSON(path string, tiers []TierGroup, summary runSummary, settings Settings) error {
+	columns, err := rosterColumns(settings.RosterFields)
+	if err != nil {
+		return err
+	}
+	out := rosterFile{Summary: summary, Tiers: []rosterTier{}}
+	for _, c := range columns {
+		out.Fields = append(out.Fields, c.Key)
+	}
+	for _, t := range tiers {
+		tier := rosterTier{Name: t.Name, Count: len(t.Patrons), Patrons: []map[string]string{}}
+		for _, p := range t.Patrons {
+			p := p
+			record := make(map[string]string, len(columns))
+			for _, c := range columns {
+				record[c.Key] = *c.Field(&p)
+			}
+			tier.Patrons = append(tier.Patrons, record)
+		}
+		out.Tiers = append(out.Tiers, tier)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// writeRosterCSV writes the filtered patrons in tier order with only the
+// ROSTER_FIELDS columns, under Patreon's own header names.
+func writeRosterCSV(path string, tiers []TierGroup, settings Settings) error {
+	columns, err := rosterColumns(settings.RosterFields)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Aliases[0]
+	}
+	w.Write(header)
+	for _, t := range tiers {
+		for _, p := range t.Patrons {
+			p := p
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				row[i] = *c.Field(&p)
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rosterTestTiers() []TierGroup {
+	return []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Alice", Tier: "Gold", Email: "alice@example.com", PledgeAmount: "10.00", Currency: "USD"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "Bob", Tier: "Silver", Email: "bob@example.com", PledgeAmount: "5.00", Currency: "EUR"}}},
+	}
+}
+
+func TestWriteRosterJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roster.json")
+	settings := defaultSettings()
+	summary := runSummary{ReferenceDate: "2024-05-01", SourceFile: "pledges.csv", TotalPaying: 2, Unpaid: 1}
+	if err := writeRosterJSON(path, rosterTestTiers(), summary, settings); err != nil {
+		t.Fatalf("writeRosterJSON failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "example.com") {
+		t.Errorf("default fields exported an email:\n%s", data)
+	}
+
+	var got rosterFile
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got.Fields, defaultRosterFields) {
+		t.Errorf("fields = %v, want %v", got.Fields, defaultRosterFields)
+	}
+	if got.Summary != summary {
+		t.Errorf("summary = %+v, want %+v", got.Summary, summary)
+	}
+	if len(got.Tiers) != 2 || got.Tiers[0].Name != "Gold" || got.Tiers[1].Count != 1 {
+		t.Fatalf("tiers = %+v", got.Tiers)
+	}
+	if p := got.Tiers[1].Patrons[0]; p["name"] != "Bob" || p["pledge_amount"] != "5.00" || p["currency"] != "EUR" {
+		t.Errorf("Bob = %v", p)
+	}
+}
+
+func TestWriteRosterCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roster.csv")
+	settings := defaultSettings()
+	settings.RosterFields = []string{"tier", "name", "email"}
+	if err := writeRosterCSV(path, rosterTestTiers(), settings); err != nil {
+		t.Fatalf("writeRosterCSV failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Tier", "Name", "Email"},
+		{"Gold", "Alice", "alice@example.com"},
+		{"Silver", "Bob", "bob@example.com"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestRosterFieldsValidation(t *testing.T) {
+	settings := defaultSettings()
+	settings.ExportCSV = true
+	settings.RosterFields = []string{"name", "shoe_size"}
+	err := settings.Validate()
+	if err == nil || !strings.Contains(err.Error(), "shoe_size") {
+		t.Errorf("Validate() = %v, want an error naming shoe_size", err)
+	}
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Rule is a boolean expression evaluated against each patron.
+// Include rules must match for a patron to be kept; exclude rules must not.
+//
+// Expressions compare patron fields with literals, for example
+//
+//	status == "Active patron" && pledge >= 5 && country != "XX"
+//
+// Supported operators are == != < <= > >= contains && || ! and parentheses.
+// == and != ignore case; contains does not.
+type Rule struct {
+	Expr    string
+	Exclude bool
+	Reason  string // diagnostics reason reported when this rule drops a patron
+	root    ruleNode
+}
+
+// defaultRules reproduce the filtering this tool has always done.
+func defaultRules() []Rule {
+	return []Rule{
+		mustCompileRule(`tier contains "Free"`, true, ReasonFreeTier),
+		mustCompileRule(`charge_status != "paid"`, true, ReasonUnpaid),
+		mustCompileRule(`access_expiration < now`, true, ReasonExpiredAccess),
+	}
+}
+
+// buildRules returns the rules configured in settings, in evaluation order.
+func buildRules(settings Settings) ([]Rule, error) {
+	var rules []Rule
+	if settings.DefaultRules {
+		rules = append(rules, defaultRules()...)
+	}
+	for _, expr := range settings.IncludeRules {
+		r, err := compileRule(expr, false, ReasonIncludeRule)
+		if err != nil {
+			return nil, fmt.Errorf("INCLUDE_RULE %q: %v", expr, err)
+		}
+		rules = append(rules, r)
+	}
+	for _, expr := range settings.ExcludeRules {
+		r, err := compileRule(expr, true, ReasonExcludeRule)
+		if err != nil {
+			return nil, fmt.Errorf("EXCLUDE_RULE %q: %v", expr, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func compileRule(expr string, exclude bool, reason string) (Rule, error) {
+	p := &ruleParser{}
+	if err := p.tokenize(expr); err != nil {
+		return Rule{}, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return Rule{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return Rule{}, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	kind, err := root.check()
+	if err != nil {
+		return Rule{}, err
+	}
+	if kind != kindBool {
+		return Rule{}, fmt.Errorf("rule must be a true/false expression")
+	}
+	return Rule{Expr: expr, Exclude: exclude, Reason: reason, root: root}, nil
+}
+
+func mustCompileRule(expr string, exclude bool, reason string) Rule {
+	r, err := compileRule(expr, exclude, reason)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Keeps reports whether the rule lets the patron through.
+func (r Rule) Keeps(p Patron, now time.Time) bool {
+	v := r.root.eval(ruleEnv{patron: p, values: p.Typed(), now: now})
+	return v.b != r.Exclude
+}
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+	kindDate
+)
+
+var kindNames = map[valueKind]string{kindString: "text", kindNumber: "number", kindBool: "true/false", kindDate: "date"}
+
+type ruleValue struct {
+	kind valueKind
+	s    string
+	n    float64
+	b    bool
+	t    time.Time
+}
+
+type ruleEnv struct {
+	patron Patron
+	values PatronValues
+	now    time.Time
+}
+
+type ruleField struct {
+	kind valueKind
+	get  func(env ruleEnv) ruleValue
+}
+
+func yesNo(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "true", "1":
+		return true
+	}
+	return false
+}
+
+// ruleFields are the typed identifiers available in rules. Every column key
+// from patronColumns is also available as plain text.
+var ruleFields = map[string]ruleField{
+	"status":            {kindString, func(e ruleEnv) ruleValue { return ruleValue{kind: kindString, s: e.patron.PatronStatus} }},
+	"charge_status":     {kindString, func(e ruleEnv) ruleValue { return ruleValue{kind: kindString, s: e.patron.LastChargeStatus} }},
+	"frequency":         {kindString, func(e ruleEnv) ruleValue { return ruleValue{kind: kindString, s: e.values.ChargeFrequency.String()} }},
+	"pledge":            {kindNumber, func(e ruleEnv) ruleValue { return ruleValue{kind: kindNumber, n: e.values.PledgeAmount.Float()} }},
+	"lifetime":          {kindNumber, func(e ruleEnv) ruleValue { return ruleValue{kind: kindNumber, n: e.values.LifetimeAmount.Float()} }},
+	"free_member":       {kindBool, func(e ruleEnv) ruleValue { return ruleValue{kind: kindBool, b: yesNo(e.patron.FreeMember)} }},
+	"free_trial":        {kindBool, func(e ruleEnv) ruleValue { return ruleValue{kind: kindBool, b: yesNo(e.patron.FreeTrial)} }},
+	"follows_you":       {kindBool, func(e ruleEnv) ruleValue { return ruleValue{kind: kindBool, b: yesNo(e.patron.FollowsYou)} }},
+	"patronage_since":   {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.values.PatronageSince} }},
+	"last_charge_date":  {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.values.LastChargeDate} }},
+	"next_charge_date":  {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.values.NextChargeDate} }},
+	"access_expiration": {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.values.AccessExpiration} }},
+	"last_updated":      {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.values.LastUpdated} }},
+	"now":               {kindDate, func(e ruleEnv) ruleValue { return ruleValue{kind: kindDate, t: e.now} }},
+}
+
+func lookupRuleField(name string) (ruleField, bool) {
+	if f, ok := ruleFields[name]; ok {
+		return f, true
+	}
+	for _, col := range patronColumns {
+		if col.Key == name {
+			field := col.Field
+			return ruleField{kindString, func(e ruleEnv) ruleValue {
+				p := e.patron
+				return ruleValue{kind: kindString, s: *field(&p)}
+			}}, true
+		}
+	}
+	return ruleField{}, false
+}
+
+// ruleNode is a node of a parsed rule expression. check runs once at compile
+// time so that eval can assume the operand kinds are valid.
+type ruleNode interface {
+	check() (valueKind, error)
+	eval(env ruleEnv) ruleValue
+}
+
+type literalNode struct{ v ruleValue }
+
+func (n *literalNode) check() (valueKind, error)  { return n.v.kind, nil }
+func (n *literalNode) eval(env ruleEnv) ruleValue { return n.v }
+
+type identNode struct {
+	name  string
+	field ruleField
+}
+
+func (n *identNode) check() (valueKind, error)  { return n.field.kind, nil }
+func (n *identNode) eval(env ruleEnv) ruleValue { return n.field.get(env) }
+
+type notNode struct{ x ruleNode }
+
+func (n *notNode) check() (valueKind, error) {
+	k, err := n.x.check()
+	if err != nil {
+		return 0, err
+	}
+	if k != kindBool {
+		return 0, fmt.Errorf("'!' needs a true/false value, got %s", kindNames[k])
+	}
+	return kindBool, nil
+}
+func (n *notNode) eval(env ruleEnv) ruleValue {
+	return ruleValue{kind: kindBool, b: !n.x.eval(env).b}
+}
+
+type logicNode struct {
+	op   string
+	l, r ruleNode
+}
+
+func (n *logicNode) check() (valueKind, error) {
+	for _, x := range []ruleNode{n.l, n.r} {
+		k, err := x.check()
+		if err != nil {
+			return 0, err
+		}
+		if k != kindBool {
+			return 0, fmt.Errorf("'%s' needs true/false values, got %s", n.op, kindNames[k])
+		}
+	}
+	return kindBool, nil
+}
+func (n *logicNode) eval(env ruleEnv) ruleValue {
+	l := n.l.eval(env).b
+	if n.op == "&&" && !l || n.op == "||" && l {
+		return ruleValue{kind: kindBool, b: l}
+	}
+	return ruleValue{kind: kindBool, b: n.r.eval(env).b}
+}
+
+type compareNode struct {
+	op   string
+	l, r ruleNode
+	kind valueKind // kind both sides are compared as
+}
+
+func (n *compareNode) check() (valueKind, error) {
+	lk, err := n.l.check()
+	if err != nil {
+		return 0, err
+	}
+	rk, err := n.r.check()
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "contains" {
+		if lk != kindString || rk != kindString {
+			return 0, fmt.Errorf("'contains' needs text on both sides")
+		}
+		n.kind = kindString
+		return kindBool, nil
+	}
+	// A text literal is converted to the kind of the field it is compared with.
+	switch {
+	case lk == rk:
+		n.kind = lk
+	case lk == kindString:
+		n.kind = rk
+		n.l, err = convertLiteral(n.l, rk)
+	case rk == kindString:
+		n.kind = lk
+		n.r, err = convertLiteral(n.r, lk)
+	default:
+		err = fmt.Errorf("cannot compare %s with %s", kindNames[lk], kindNames[rk])
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n.kind == kindBool && n.op != "==" && n.op != "!=" {
+		return 0, fmt.Errorf("'%s' cannot be used with true/false values", n.op)
+	}
+	return kindBool, nil
+}
+
+// convertLiteral turns a quoted literal into a number or date so it can be
+// compared with a field of that kind. An empty string becomes the zero value,
+// which lets rules test for missing dates with == "".
+func convertLiteral(x ruleNode, kind valueKind) (ruleNode, error) {
+	lit, ok := x.(*literalNode)
+	if !ok {
+		return nil, fmt.Errorf("cannot compare text with %s", kindNames[kind])
+	}
+	v := ruleValue{kind: kind}
+	switch kind {
+	case kindNumber:
+		if lit.v.s != "" {
+			n, err := strconv.ParseFloat(lit.v.s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", lit.v.s)
+			}
+			v.n = n
+		}
+	case kindDate:
+		t, err := parsePatreonDate(lit.v.s)
+		if err != nil {
+			return nil, err
+		}
+		v.t = t
+	case kindBool:
+		v.b = yesNo(lit.v.s)
+	}
+	return &literalNode{v}, nil
+}
+
+func (n *compareNode) eval(env ruleEnv) ruleValue {
+	l, r := n.l.eval(env), n.r.eval(env)
+	var c int
+	switch n.kind {
+	case kindString:
+		if n.op == "contains" {
+			return ruleValue{kind: kindBool, b: strings.Contains(l.s, r.s)}
+		}
+		c = strings.Compare(strings.ToLower(strings.TrimSpace(l.s)), strings.ToLower(strings.TrimSpace(r.s)))
+	case kindNumber:
+		c = compareFloat(l.n, r.n)
+	case kindBool:
+		if l.b != r.b {
+			c = 1
+		}
+	case kindDate:
+		// Ordering against a missing date is never true, so an absent
+		// expiration does not count as expired.
+		if (l.t.IsZero() || r.t.IsZero()) && n.op != "==" && n.op != "!=" {
+			return ruleValue{kind: kindBool}
+		}
+		c = compareTime(l.t, r.t)
+	}
+	var b bool
+	switch n.op {
+	case "==":
+		b = c == 0
+	case "!=":
+		b = c != 0
+	case "<":
+		b = c < 0
+	case "<=":
+		b = c <= 0
+	case ">":
+		b = c > 0
+	case ">=":
+		b = c >= 0
+	}
+	return ruleValue{kind: kindBool, b: b}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+type ruleToken struct {
+	kind string // "ident", "string", "number" or "op"
+	text string
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) tokenize(s string) error {
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string")
+			}
+			p.tokens = append(p.tokens, ruleToken{"string", b.String()})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, ruleToken{"number", s[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			p.tokens = append(p.tokens, ruleToken{"ident", strings.ToLower(s[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected character %q", c)
+			}
+			p.tokens = append(p.tokens, ruleToken{"op", op})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ruleToken{}
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	l, err := p.parseAnd()
+	for err == nil && p.peek().text == "||" {
+		p.pos++
+		var r ruleNode
+		r, err = p.parseAnd()
+		l = &logicNode{op: "||", l: l, r: r}
+	}
+	return l, err
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	l, err := p.parseNot()
+	for err == nil && p.peek().text == "&&" {
+		p.pos++
+		var r ruleNode
+		r, err = p.parseNot()
+		l = &logicNode{op: "&&", l: l, r: r}
+	}
+	return l, err
+}
+
+func (p *ruleParser) parseNot() (ruleNode, error) {
+	if t := p.peek(); t.kind == "op" && t.text == "!" {
+		p.pos++
+		x, err := p.parseNot()
+		return &notNode{x}, err
+	}
+	return p.parseCompare()
+}
+
+func (p *ruleParser) parseCompare() (ruleNode, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=", "contains":
+	default:
+		return l, nil
+	}
+	if t.kind == "string" {
+		return l, nil
+	}
+	p.pos++
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: t.text, l: l, r: r}, nil
+}
+
+func (p *ruleParser) parsePrimary() (ruleNode, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case "string":
+		return &literalNode{ruleValue{kind: kindString, s: t.text}}, nil
+	case "number":
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", t.text)
+		}
+		return &literalNode{ruleValue{kind: kindNumber, n: n}}, nil
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			return &literalNode{ruleValue{kind: kindBool, b: t.text == "true"}}, nil
+		}
+		field, ok := lookupRuleField(t.text)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", t.text)
+		}
+		return &identNode{name: t.text, field: field}, nil
+	case "op":
+		if t.text == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.peek().text != ")" {
+				return nil, fmt.Errorf("missing ')'")
+			}
+			p.pos++
+			return x, nil
+		}
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return nil, fmt.Errorf("unexpected end of rule")
+}
+
+// RuleStat counts how many patrons a single rule keeps and drops when it is
+// evaluated on its own against every patron.
+type RuleStat struct {
+	Rule    Rule
+	Kept    int
+	Dropped int
+}
+
+func ruleStats(patrons []Patron, rules []Rule, now time.Time) []RuleStat {
+	stats := make([]RuleStat, len(rules))
+	for i, r := range rules {
+		stats[i].Rule = r
+		for _, p := range patrons {
+			if r.Keeps(p, now) {
+				stats[i].Kept++
+			} else {
+				stats[i].Dropped++
+			}
+		}
+	}
+	return stats
+}
+
+func printRuleStats(stats []RuleStat, kept int) {
+	fmt.Println("----- Rules (dry run) -----")
+	for _, s := range stats {
+		kind := "INCLUDE"
+		if s.Rule.Exclude {
+			kind = "EXCLUDE"
+		}
+		fmt.Printf("%s %s\n    keeps %d, drops %d\n", kind, s.Rule.Expr, s.Kept, s.Dropped)
+	}
+	fmt.Printf("Patrons kept by all rules: %d\n", kept)
+	fmt.Println("---------------------------")
+}
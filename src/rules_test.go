@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompileRule_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`pledge >=`,
+		`unknown_field == "x"`,
+		`pledge contains "5"`,
+		`pledge >= "five"`,
+		`tier == "Gold" &&`,
+		`(tier == "Gold"`,
+		`tier`,
+		`"unterminated`,
+		`free_member < true`,
+	} {
+		if _, err := compileRule(expr, false, ReasonIncludeRule); err == nil {
+			t.Errorf("expected compile error for %q", expr)
+		}
+	}
+}
+
+func TestRule_Keeps(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alice := Patron{Name: "Alice", PatronStatus: "Active patron", PledgeAmount: "5.00", Currency: "USD", Country: "SE", Tier: "Gold", FreeTrial: "No"}
+	bob := Patron{Name: "Bob", PatronStatus: "Active patron", PledgeAmount: "3.00", Currency: "USD", Country: "SE", Tier: "Silver", FreeTrial: "Yes"}
+	cases := []struct {
+		expr  string
+		alice bool
+		bob   bool
+	}{
+		{`status == "Active patron" && pledge >= 5 && country != "XX"`, true, false},
+		{`status == "active PATRON"`, true, true},
+		{`tier contains "Gold" || pledge < 4`, true, true},
+		{`!(tier == "Gold")`, false, true},
+		{`free_trial`, false, true},
+		{`free_trial == false`, true, false},
+		{`access_expiration < now`, false, false},
+		{`access_expiration == ""`, true, true},
+		{`now > "2023-12-31"`, true, true},
+		{`name == "Alice"`, true, false},
+	}
+	for _, c := range cases {
+		r, err := compileRule(c.expr, false, ReasonIncludeRule)
+		if err != nil {
+			t.Errorf("compileRule(%q) unexpected error: %v", c.expr, err)
+			continue
+		}
+		if got := r.Keeps(alice, now); got != c.alice {
+			t.Errorf("%q on Alice = %v, want %v", c.expr, got, c.alice)
+		}
+		if got := r.Keeps(bob, now); got != c.bob {
+			t.Errorf("%q on Bob = %v, want %v", c.expr, got, c.bob)
+		}
+	}
+}
+
+func TestFilterPatrons_CustomRules(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	settings := Settings{
+		DefaultRules: true,
+		IncludeRules: []string{`pledge >= 5`},
+		ExcludeRules: []string{`country == "XX"`},
+	}
+	rules, err := buildRules(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	patrons := []Patron{
+		{Name: "A", Tier: "Gold", LastChargeStatus: "Paid", PledgeAmount: "10"},
+		{Name: "B", Tier: "Gold", LastChargeStatus: "Paid", PledgeAmount: "1"},
+		{Name: "C", Tier: "Gold", LastChargeStatus: "Paid", PledgeAmount: "10", Country: "XX"},
+		{Name: "D", Tier: "Gold", LastChargeStatus: "Declined", PledgeAmount: "10"},
+	}
+	diag := &Diagnostics{}
+	filtered, _, unpaid := filterPatrons(patrons, rules, now, 0, diag)
+	if len(filtered) != 1 || filtered[0].Name != "A" {
+		t.Errorf("unexpected filtered: %v", filtered)
+	}
+	if unpaid != 1 {
+		t.Errorf("expected 1 unpaid, got %d", unpaid)
+	}
+	if diag.Count(ReasonIncludeRule) != 1 || diag.Count(ReasonExcludeRule) != 1 {
+		t.Errorf("unexpected diagnostics: %+v", diag.Entries)
+	}
+
+	stats := ruleStats(patrons, rules, now)
+	if len(stats) != 5 {
+		t.Fatalf("expected 5 rule stats, got %d", len(stats))
+	}
+	if stats[3].Kept != 3 || stats[3].Dropped != 1 {
+		t.Errorf("unexpected stats for %q: %+v", stats[3].Rule.Expr, stats[3])
+	}
+}
+
+func TestFilterPatrons_DefaultRulesOff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules, err := buildRules(Settings{DefaultRules: false})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	patrons := []Patron{
+		{Name: "A", Tier: "Gold", LastChargeStatus: "Paid"},
+		{Name: "B", Tier: "Free", LastChargeStatus: "Paid"},
+		{Name: "C", Tier: "Gold", LastChargeStatus: "Declined", AccessExpiration: "2023-06-01"},
+	}
+	filtered, expired, unpaid := filterPatrons(patrons, rules, now, 0, &Diagnostics{})
+	if len(filtered) != 3 || expired != 0 || unpaid != 0 {
+		t.Errorf("expected every patron kept, got %d (expired %d, unpaid %d)", len(filtered), expired, unpaid)
+	}
+}
+
+func TestLoadSettings_Rules(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "settings.conf")
+	content := `
+DEFAULT_RULES=false
+INCLUDE_RULE=pledge >= 5
+INCLUDE_RULE=country != "XX"
+EXCLUDE_RULE=tier == "Staff"
+DRY_RUN=true
+`
+	if err := os.WriteFile(confPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp settings.conf: %v", err)
+	}
+	settings := LoadSettings(confPath)
+	if settings.DefaultRules || !settings.DryRun {
+		t.Errorf("unexpected flags: %+v", settings)
+	}
+	if len(settings.IncludeRules) != 2 || len(settings.ExcludeRules) != 1 {
+		t.Errorf("unexpected rules: %v / %v", settings.IncludeRules, settings.ExcludeRules)
+	}
+	rules, err := buildRules(settings)
+	if err != nil || len(rules) != 3 {
+		t.Errorf("expected 3 rules, got %d (%v)", len(rules), err)
+	}
+}
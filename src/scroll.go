@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Easing curves for SVG_SCROLL_EASING, as cubic Bézier control points
+// (x1 y1 x2 y2), the same curves CSS uses for its named timing functions.
+var scrollEasings = map[string][4]float64{
+	"linear":      {0, 0, 1, 1},
+	"ease":        {0.25, 0.1, 0.25, 1},
+	"ease-in":     {0.42, 0, 1, 1},
+	"ease-out":    {0, 0, 0.58, 1},
+	"ease-in-out": {0.42, 0, 0.58, 1},
+}
+
+// scrollTimeline describes how a credits roll moves through a fixed viewport:
+// the first screen is shown for HoldStart seconds, the names scroll up over
+// Duration seconds until the last name reaches the bottom of the viewport, and
+// the last screen is shown for HoldEnd seconds.
+type scrollTimeline struct {
+	HoldStart float64
+	Duration  float64
+	HoldEnd   float64
+	Easing    string
+	Distance  int // how far the names move, in pixels
+}
+
+func newScrollTimeline(layout creditsLayout, settings Settings) scrollTimeline {
+	distance := layout.Height - settings.CanvasHeight
+	if distance < 0 {
+		distance = 0
+	}
+	return scrollTimeline{
+		HoldStart: settings.ScrollHoldStart,
+		Duration:  settings.ScrollDuration,
+		HoldEnd:   settings.ScrollHoldEnd,
+		Easing:    settings.ScrollEasing,
+		Distance:  distance,
+	}
+}
+
+// Total is the length of the whole animation in seconds.
+func (st scrollTimeline) Total() float64 {
+	return st.HoldStart + st.Duration + st.HoldEnd
+}
+
+// Offset returns how far the names have moved t seconds into the animation.
+func (st scrollTimeline) Offset(t float64) float64 {
+	switch {
+	case t <= st.HoldStart:
+		return 0
+	case t >= st.HoldStart+st.Duration:
+		return float64(st.Distance)
+	}
+	progress := (t - st.HoldStart) / st.Duration
+	return cubicBezierEase(scrollEasings[st.Easing], progress) * float64(st.Distance)
+}
+
+// cubicBezierEase evaluates a CSS-style easing curve at x (0 to 1), the same
+// way browsers apply keySplines, so frames match the animated SVG.
+func cubicBezierEase(c [4]float64, x float64) float64 {
+	bezier := func(p1, p2, t float64) float64 {
+		u := 1 - t
+		return 3*u*u*t*p1 + 3*u*t*t*p2 + t*t*t
+	}
+	// The x of the curve only grows with t, so bisection finds t for x.
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 40; i++ {
+		mid := (lo + hi) / 2
+		if bezier(c[0], c[2], mid) < x {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return bezier(c[1], c[3], (lo+hi)/2)
+}
+
+// ExportScrollingSVG writes the tiers as an SVG_WIDTH x SVG_HEIGHT SVG whose
+// names scroll up by themselves, using the same layout as ExportTiersSVG.
+func ExportScrollingSVG(tiers []TierGroup, outputPath string, settings Settings) error {
+	layout := buildLayout(tiers, settings)
+	if err := overflowError(layout); err != nil {
+		return err
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	writeScrollingSVG(f, layout, newScrollTimeline(layout, settings), settings)
+	return nil
+}
+
+// writeScrollingSVG renders layout inside a viewport of the canvas size and
+// moves it with a SMIL animateTransform, which browsers and most editors that
+// import animated SVG play without scripts.
+func writeScrollingSVG(w io.Writer, layout creditsLayout, st scrollTimeline, settings Settings) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		layout.Width, settings.CanvasHeight, layout.Width, settings.CanvasHeight)
+	writeSVGMetadata(w, settings, "scroll-duration", formatNumber(st.Total()))
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="none"/>`)
+	fmt.Fprint(w, `<g>`)
+	if st.Distance > 0 {
+		writeScrollAnimation(w, st)
+	}
+	writeSVGItems(w, layout.Items, 0, settings)
+	fmt.Fprint(w, `</g></svg>`)
+}
+
+// writeScrollAnimation writes the animateTransform for st. Hold times become
+// key frames that stay in place, and the scroll in between uses the easing curve.
+func writeScrollAnimation(w io.Writer, st scrollTimeline) {
+	total := st.Total()
+	end := fmt.Sprintf("0 %d", -st.Distance)
+	values := []string{"0 0"}
+	keyTimes := []string{"0"}
+	var splines []string
+	linear := "0 0 1 1"
+	if st.HoldStart > 0 {
+		values = append(values, "0 0")
+		keyTimes = append(keyTimes, formatNumber(st.HoldStart/total))
+		splines = append(splines, linear)
+	}
+	values = append(values, end)
+	keyTimes = append(keyTimes, formatNumber((st.HoldStart+st.Duration)/total))
+	c := scrollEasings[st.Easing]
+	splines = append(splines, fmt.Sprintf("%s %s %s %s", formatNumber(c[0]), formatNumber(c[1]), formatNumber(c[2]), formatNumber(c[3])))
+	if st.HoldEnd > 0 {
+		values = append(values, end)
+		keyTimes = append(keyTimes, "1")
+		splines = append(splines, linear)
+	}
+	fmt.Fprintf(w, `<animateTransform attributeName="transform" type="translate" values="%s" keyTimes="%s" calcMode="spline" keySplines="%s" dur="%ss" fill="freeze"/>`,
+		strings.Join(values, ";"), strings.Join(keyTimes, ";"), strings.Join(splines, ";"), formatNumber(total))
+}
+
+// formatNumber prints a number with at most four decimals and no trailing zeros.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func scrollTestSettings() Settings {
+	settings := pagingTestSettings()
+	settings.ScrollSVG = true
+	settings.CanvasHeight = 200
+	settings.ScrollDuration = 20
+	return settings
+}
+
+func TestWriteScrollingSVG_Animation(t *testing.T) {
+	settings := scrollTestSettings()
+	settings.ScrollHoldStart = 5
+	settings.ScrollHoldEnd = 5
+	settings.ScrollEasing = "ease-in-out"
+	layout := creditsLayout{Width: 800, Height: 500}
+	var buf bytes.Buffer
+	writeScrollingSVG(&buf, layout, newScrollTimeline(layout, settings), settings)
+	out := buf.String()
+
+	for _, want := range []string{
+		`width="800" height="200" viewBox="0 0 800 200"`,
+		`values="0 0;0 0;0 -300;0 -300"`,
+		`keyTimes="0;0.1667;0.8333;1"`,
+		`keySplines="0 0 1 1;0.42 0 0.58 1;0 0 1 1"`,
+		`dur="30s"`,
+		`scroll-duration="30"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in %s", want, out)
+		}
+	}
+}
+
+func TestWriteScrollingSVG_NoHolds(t *testing.T) {
+	settings := scrollTestSettings()
+	layout := creditsLayout{Width: 800, Height: 500}
+	var buf bytes.Buffer
+	writeScrollingSVG(&buf, layout, newScrollTimeline(layout, settings), settings)
+	if !strings.Contains(buf.String(), `values="0 0;0 -300" keyTimes="0;1" calcMode="spline" keySplines="0 0 1 1"`) {
+		t.Errorf("unexpected animation: %s", buf.String())
+	}
+}
+
+func TestWriteScrollingSVG_ShortListDoesNotMove(t *testing.T) {
+	settings := scrollTestSettings()
+	layout := creditsLayout{Width: 800, Height: 150}
+	var buf bytes.Buffer
+	writeScrollingSVG(&buf, layout, newScrollTimeline(layout, settings), settings)
+	if strings.Contains(buf.String(), "animateTransform") {
+		t.Error("did not expect an animation when every name fits the viewport")
+	}
+}
+
+func TestExportScrollingSVG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all_names_scroll.svg")
+	if err := ExportScrollingSVG([]TierGroup{namedTier("Gold", 30)}, path, scrollTestSettings()); err != nil {
+		t.Fatalf("ExportScrollingSVG failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "Gold patron 29") || !strings.Contains(content, "<animateTransform") {
+		t.Errorf("unexpected SVG: %s", content)
+	}
+}
+
+func TestScrollTimeline_Offset(t *testing.T) {
+	st := scrollTimeline{HoldStart: 2, Duration: 10, HoldEnd: 3, Easing: "linear", Distance: 1000}
+	for _, tc := range []struct {
+		t, want float64
+	}{
+		{0, 0}, {2, 0}, {7, 500}, {12, 1000}, {14, 1000},
+	} {
+		if got := st.Offset(tc.t); math.Abs(got-tc.want) > 0.01 {
+			t.Errorf("Offset(%v) = %v, want %v", tc.t, got, tc.want)
+		}
+	}
+
+	st.Easing = "ease-in"
+	if got := st.Offset(7); got >= 500 {
+		t.Errorf("expected ease-in to be behind linear halfway, got %v", got)
+	}
+	st.Easing = "ease-in-out"
+	if got := st.Offset(7); math.Abs(got-500) > 0.01 {
+		t.Errorf("expected ease-in-out to be halfway at the midpoint, got %v", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 // Settings holds configuration values with type safety
@@ -14,6 +15,23 @@ type Settings struct {
 	OutputDir      string
 	DefaultCSVFile string
 
+	ExportDiagnostics bool
+	DiagnosticsFormat string
+
+	DefaultRules bool
+	IncludeRules []string
+	ExcludeRules []string
+	DryRun       bool
+
+	GracePeriodDays int
+	GracePeriodKeep bool
+	AsOfDate        string
+
+	TierOrder    []string
+	TierAliases  map[string]string
+	TXTHideTiers []string
+	SVGHideTiers []string
+
 	Width              int
 	Margin             int
 	ColGap             int
@@ -24,17 +42,92 @@ type Settings struct {
 	ColumnColors       []string
 	RandomizeSVGColors bool
 	UserColorMap       map[string]string
+
+	SVGLayout       string
+	HeadingFontSize int
+	HeadingColor    string
+	SectionSpacing  int
+	TierHeadings    map[string]string
+	TierFontSizes   map[string]int
+	TierLineHeights map[string]int
+	TierColumns     map[string]int
+	TierColors      map[string][]string
+	TierSpacing     map[string]int
+
+	FontFile    string
+	Overflow    string
+	MinFontSize int
+
+	Fit          bool
+	CanvasHeight int
+	MaxFontSize  int
+	MaxColumns   int
+
+	MaxPageHeight   int
+	MaxNamesPerPage int
+
+	ScrollSVG       bool
+	ScrollDuration  float64 // seconds
+	ScrollEasing    string
+	ScrollHoldStart float64 // seconds
+	ScrollHoldEnd   float64 // seconds
+
+	ExportPNG     bool
+	PNGScale      float64
+	PNGBackground string
+	PNGFrames     bool
+	PNGFPS        int
+	PNGDPI        int
+
+	ExportASS         bool
+	ExportSRT         bool
+	SRTNamesPerSecond float64
+	SRTNamesPerCue    int
+
+	ExportFCPXML bool
+
+	ExportHTML       bool
+	HTMLTitle        string
+	HTMLTemplateFile string
+	HTMLHideTiers    []string
+
+	TXTTemplateFiles []string
+
+	ExportJSON   bool
+	ExportCSV    bool
+	RosterFields []string
+
+	ExportShipping      bool
+	ShippingTiers       []string
+	ShippingRules       []string
+	ShippingHomeCountry string
+
+	HistoryDir string
+
+	ExportStats       bool
+	StatsTopCountries int
+
+	ReportingCurrency string
+	RatesFile         string
 }
 
-// LoadSettings loads settings from settings.conf and returns a Settings object
-func LoadSettings(path string) Settings {
-	fmt.Print("Loading settings.conf\n")
-	settings := Settings{
+// defaultSettings returns the values used when settings.conf is missing or
+// does not mention a setting.
+func defaultSettings() Settings {
+	return Settings{
 		ExportSVG:      true,
 		ExportTXT:      true,
 		OutputDir:      "output",
 		DefaultCSVFile: "pledges.csv",
 
+		ExportDiagnostics: true,
+		DiagnosticsFormat: "csv",
+
+		DefaultRules: true,
+
+		GracePeriodDays: 0,
+		GracePeriodKeep: true,
+
 		Width:              1161,
 		Margin:             26,
 		ColGap:             54,
@@ -45,11 +138,62 @@ func LoadSettings(path string) Settings {
 		ColumnColors:       []string{"#3aff22", "#c622ff", "#a8ff21"},
 		RandomizeSVGColors: true,
 		UserColorMap:       nil,
+
+		SVGLayout:       "flat",
+		HeadingFontSize: 24,
+		HeadingColor:    "#ffffff",
+		SectionSpacing:  40,
+
+		Overflow:    "none",
+		MinFontSize: 8,
+
+		Fit:          false,
+		CanvasHeight: 1080,
+		MaxFontSize:  48,
+		MaxColumns:   6,
+
+		ScrollSVG:      false,
+		ScrollDuration: 30,
+		ScrollEasing:   "linear",
+
+		ExportPNG:     false,
+		PNGScale:      1,
+		PNGBackground: "transparent",
+		PNGFrames:     false,
+		PNGFPS:        30,
+
+		ExportASS:         false,
+		ExportSRT:         false,
+		SRTNamesPerSecond: 2,
+		SRTNamesPerCue:    3,
+
+		ExportFCPXML: false,
+
+		ExportHTML: false,
+		HTMLTitle:  "Thank you to our supporters",
+
+		ExportJSON:   false,
+		ExportCSV:    false,
+		RosterFields: defaultRosterFields,
+
+		ExportShipping: false,
+
+		HistoryDir: "",
+
+		ExportStats:       false,
+		StatsTopCountries: 10,
 	}
+}
+
+// LoadSettings loads settings from settings.conf and returns a Settings object.
+// Its messages go to stderr, so subcommands can write reports to stdout.
+func LoadSettings(path string) Settings {
+	fmt.Fprint(os.Stderr, "Loading settings.conf\n")
+	settings := defaultSettings()
 
 	file, err := os.Open(path)
 	if err != nil {
-		fmt.Print("No settings.conf found. Loading defaults. \n If you would like to change the behaviour of this exporter, create a settings.conf file in the same directory.\n See README for help! \n")
+		fmt.Fprint(os.Stderr, "No settings.conf found. Loading defaults. \n If you would like to change the behaviour of this exporter, create a settings.conf file in the same directory.\n See README for help! \n")
 		return settings // Use defaults if file missing
 	}
 	defer file.Close()
@@ -76,6 +220,26 @@ func LoadSettings(path string) Settings {
 			settings.OutputDir = val
 		case "DEFAULT_CSV_FILE":
 			settings.DefaultCSVFile = val
+		case "EXPORT_DIAGNOSTICS":
+			settings.ExportDiagnostics = strings.ToLower(val) == "true"
+		case "DIAGNOSTICS_FORMAT":
+			settings.DiagnosticsFormat = strings.ToLower(val)
+		case "DEFAULT_RULES":
+			settings.DefaultRules = strings.ToLower(val) == "true"
+		case "INCLUDE_RULE":
+			// May be given more than once; every rule must match.
+			settings.IncludeRules = append(settings.IncludeRules, val)
+		case "EXCLUDE_RULE":
+			// May be given more than once; any matching rule excludes.
+			settings.ExcludeRules = append(settings.ExcludeRules, val)
+		case "DRY_RUN":
+			settings.DryRun = strings.ToLower(val) == "true"
+		case "GRACE_PERIOD_DAYS":
+			fmt.Sscanf(val, "%d", &settings.GracePeriodDays)
+		case "GRACE_PERIOD_KEEP":
+			settings.GracePeriodKeep = strings.ToLower(val) == "true"
+		case "AS_OF_DATE":
+			settings.AsOfDate = val
 		case "SVG_WIDTH":
 			fmt.Sscanf(val, "%d", &settings.Width)
 		case "SVG_MARGIN_TO_EDGE":
@@ -96,14 +260,127 @@ func LoadSettings(path string) Settings {
 			settings.RandomizeSVGColors = strings.ToLower(val) == "true"
 		case "USER_COLOR_MAP":
 			// Format: name1:#FFF,name2:#000
-			settings.UserColorMap = make(map[string]string)
-			pairs := strings.Split(val, ",")
-			for _, pair := range pairs {
-				kv := strings.SplitN(pair, ":", 2)
-				if len(kv) == 2 {
-					settings.UserColorMap[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
-				}
+			settings.UserColorMap = parseNameMap(val)
+		case "SVG_LAYOUT":
+			settings.SVGLayout = strings.ToLower(val)
+		case "SVG_HEADING_FONTSIZE":
+			fmt.Sscanf(val, "%d", &settings.HeadingFontSize)
+		case "SVG_HEADING_COLOR":
+			settings.HeadingColor = val
+		case "SVG_SECTION_SPACING":
+			fmt.Sscanf(val, "%d", &settings.SectionSpacing)
+		case "SVG_TIER_HEADINGS":
+			// Format: Gold:Gold Supporters,Silver:Silver Supporters
+			settings.TierHeadings = parseNameMap(val)
+		case "SVG_TIER_FONTSIZES":
+			settings.TierFontSizes = parseIntMap(val)
+		case "SVG_TIER_LINEHEIGHTS":
+			settings.TierLineHeights = parseIntMap(val)
+		case "SVG_TIER_COLUMNS":
+			settings.TierColumns = parseIntMap(val)
+		case "SVG_TIER_COLORS":
+			// Format: Gold:#ffd700|#ffffff,Silver:#c0c0c0
+			settings.TierColors = make(map[string][]string)
+			for tier, colors := range parseNameMap(val) {
+				settings.TierColors[tier] = strings.Split(colors, "|")
 			}
+		case "SVG_TIER_SPACING":
+			settings.TierSpacing = parseIntMap(val)
+		case "SVG_FONT_FILE":
+			settings.FontFile = val
+		case "SVG_OVERFLOW":
+			settings.Overflow = strings.ToLower(val)
+		case "SVG_MIN_FONTSIZE":
+			fmt.Sscanf(val, "%d", &settings.MinFontSize)
+		case "SVG_FIT":
+			settings.Fit = strings.ToLower(val) == "true"
+		case "SVG_HEIGHT":
+			fmt.Sscanf(val, "%d", &settings.CanvasHeight)
+		case "SVG_MAX_FONTSIZE":
+			fmt.Sscanf(val, "%d", &settings.MaxFontSize)
+		case "SVG_MAX_COLUMNS":
+			fmt.Sscanf(val, "%d", &settings.MaxColumns)
+		case "SVG_MAX_PAGE_HEIGHT":
+			fmt.Sscanf(val, "%d", &settings.MaxPageHeight)
+		case "SVG_MAX_NAMES_PER_PAGE":
+			fmt.Sscanf(val, "%d", &settings.MaxNamesPerPage)
+		case "SVG_SCROLL":
+			settings.ScrollSVG = strings.ToLower(val) == "true"
+		case "SVG_SCROLL_DURATION":
+			fmt.Sscanf(val, "%g", &settings.ScrollDuration)
+		case "SVG_SCROLL_EASING":
+			settings.ScrollEasing = strings.ToLower(val)
+		case "SVG_SCROLL_HOLD_START":
+			fmt.Sscanf(val, "%g", &settings.ScrollHoldStart)
+		case "SVG_SCROLL_HOLD_END":
+			fmt.Sscanf(val, "%g", &settings.ScrollHoldEnd)
+		case "EXPORT_PNG":
+			settings.ExportPNG = strings.ToLower(val) == "true"
+		case "PNG_SCALE":
+			fmt.Sscanf(val, "%g", &settings.PNGScale)
+		case "PNG_BACKGROUND":
+			settings.PNGBackground = val
+		case "PNG_FRAMES":
+			settings.PNGFrames = strings.ToLower(val) == "true"
+		case "PNG_FPS":
+			fmt.Sscanf(val, "%d", &settings.PNGFPS)
+		case "PNG_DPI":
+			fmt.Sscanf(val, "%d", &settings.PNGDPI)
+		case "EXPORT_ASS":
+			settings.ExportASS = strings.ToLower(val) == "true"
+		case "EXPORT_SRT":
+			settings.ExportSRT = strings.ToLower(val) == "true"
+		case "SRT_NAMES_PER_SECOND":
+			fmt.Sscanf(val, "%g", &settings.SRTNamesPerSecond)
+		case "SRT_NAMES_PER_CUE":
+			fmt.Sscanf(val, "%d", &settings.SRTNamesPerCue)
+		case "EXPORT_FCPXML":
+			settings.ExportFCPXML = strings.ToLower(val) == "true"
+		case "EXPORT_HTML":
+			settings.ExportHTML = strings.ToLower(val) == "true"
+		case "HTML_TITLE":
+			settings.HTMLTitle = val
+		case "HTML_TEMPLATE_FILE":
+			settings.HTMLTemplateFile = val
+		case "HTML_HIDE_TIERS":
+			settings.HTMLHideTiers = splitList(val)
+		case "EXPORT_JSON":
+			settings.ExportJSON = strings.ToLower(val) == "true"
+		case "EXPORT_CSV":
+			settings.ExportCSV = strings.ToLower(val) == "true"
+		case "ROSTER_FIELDS":
+			settings.RosterFields = splitList(val)
+		case "EXPORT_SHIPPING":
+			settings.ExportShipping = strings.ToLower(val) == "true"
+		case "SHIPPING_TIERS":
+			settings.ShippingTiers = splitList(val)
+		case "SHIPPING_RULE":
+			// May be given more than once; every rule must match.
+			settings.ShippingRules = append(settings.ShippingRules, val)
+		case "SHIPPING_HOME_COUNTRY":
+			settings.ShippingHomeCountry = strings.ToUpper(val)
+		case "EXPORT_STATS":
+			settings.ExportStats = strings.ToLower(val) == "true"
+		case "STATS_TOP_COUNTRIES":
+			fmt.Sscanf(val, "%d", &settings.StatsTopCountries)
+		case "REPORTING_CURRENCY":
+			settings.ReportingCurrency = strings.ToUpper(val)
+		case "RATES_FILE":
+			settings.RatesFile = val
+		case "HISTORY_DIR":
+			settings.HistoryDir = val
+		case "TXT_TEMPLATE_FILE":
+			// May be given more than once; each template writes its own file.
+			settings.TXTTemplateFiles = append(settings.TXTTemplateFiles, val)
+		case "TIER_ORDER":
+			settings.TierOrder = splitList(val)
+		case "TIER_ALIASES":
+			// Format: Old Name:New Name,Other Old Name:New Name
+			settings.TierAliases = parseNameMap(val)
+		case "TXT_HIDE_TIERS":
+			settings.TXTHideTiers = splitList(val)
+		case "SVG_HIDE_TIERS":
+			settings.SVGHideTiers = splitList(val)
 		}
 	}
 
@@ -115,6 +392,41 @@ func LoadSettings(path string) Settings {
 	return settings
 }
 
+// parseNameMap parses "name:value,name:value" into a map keyed by lower-case name.
+func parseNameMap(val string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(val, ",") {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) == 2 {
+			m[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+	return m
+}
+
+// parseIntMap parses "name:number,name:number", skipping entries that are not numbers.
+func parseIntMap(val string) map[string]int {
+	m := make(map[string]int)
+	for name, v := range parseNameMap(val) {
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil {
+			m[name] = n
+		}
+	}
+	return m
+}
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(val string) []string {
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func (s *Settings) Validate() error {
 	if s.Width <= 0 {
 		return fmt.Errorf("SVG_WIDTH must be greater than 0")
@@ -134,14 +446,165 @@ func (s *Settings) Validate() error {
 	if s.Columns <= 0 {
 		return fmt.Errorf("SVG_COLUMNS must be greater than 0")
 	}
+	if s.GracePeriodDays < 0 {
+		return fmt.Errorf("GRACE_PERIOD_DAYS cannot be negative")
+	}
+	if _, err := parsePatreonDate(s.AsOfDate); err != nil {
+		return fmt.Errorf("AS_OF_DATE: %v", err)
+	}
 	if s.OutputDir == "" {
 		return fmt.Errorf("OUTPUT_DIR cannot be empty")
 	}
 	if s.DefaultCSVFile == "" {
 		return fmt.Errorf("DEFAULT_CSV_FILE cannot be empty")
 	}
+	if s.DiagnosticsFormat != "csv" && s.DiagnosticsFormat != "json" {
+		return fmt.Errorf("DIAGNOSTICS_FORMAT must be csv or json")
+	}
 	if len(s.ColumnColors) == 0 {
 		return fmt.Errorf("SVG_COLUMN_COLORS must have at least one color")
 	}
+	if s.SVGLayout != "flat" && s.SVGLayout != "sections" {
+		return fmt.Errorf("SVG_LAYOUT must be flat or sections")
+	}
+	if s.HeadingFontSize <= 0 {
+		return fmt.Errorf("SVG_HEADING_FONTSIZE must be greater than 0")
+	}
+	if s.SectionSpacing < 0 {
+		return fmt.Errorf("SVG_SECTION_SPACING cannot be negative")
+	}
+	switch s.Overflow {
+	case OverflowNone, OverflowShrink, OverflowWrap, OverflowTruncate, OverflowFail:
+	default:
+		return fmt.Errorf("SVG_OVERFLOW must be none, shrink, wrap, truncate or fail")
+	}
+	if s.MinFontSize <= 0 {
+		return fmt.Errorf("SVG_MIN_FONTSIZE must be greater than 0")
+	}
+	if s.Fit {
+		if s.CanvasHeight <= 0 {
+			return fmt.Errorf("SVG_HEIGHT must be greater than 0")
+		}
+		if s.MaxFontSize < s.MinFontSize {
+			return fmt.Errorf("SVG_MAX_FONTSIZE cannot be smaller than SVG_MIN_FONTSIZE")
+		}
+		if s.MaxColumns <= 0 {
+			return fmt.Errorf("SVG_MAX_COLUMNS must be greater than 0")
+		}
+	}
+	if s.MaxPageHeight < 0 {
+		return fmt.Errorf("SVG_MAX_PAGE_HEIGHT cannot be negative")
+	}
+	if s.MaxNamesPerPage < 0 {
+		return fmt.Errorf("SVG_MAX_NAMES_PER_PAGE cannot be negative")
+	}
+	if s.ScrollSVG || s.PNGFrames || s.ExportASS || s.ExportFCPXML {
+		if s.CanvasHeight <= 0 {
+			return fmt.Errorf("SVG_HEIGHT must be greater than 0")
+		}
+		if s.ScrollDuration <= 0 {
+			return fmt.Errorf("SVG_SCROLL_DURATION must be greater than 0")
+		}
+		if s.ScrollHoldStart < 0 || s.ScrollHoldEnd < 0 {
+			return fmt.Errorf("SVG_SCROLL_HOLD_START and SVG_SCROLL_HOLD_END cannot be negative")
+		}
+		if _, ok := scrollEasings[s.ScrollEasing]; !ok {
+			return fmt.Errorf("SVG_SCROLL_EASING must be linear, ease, ease-in, ease-out or ease-in-out")
+		}
+	}
+	if s.FontFile != "" {
+		if _, err := loadFontCached(s.FontFile); err != nil {
+			return fmt.Errorf("SVG_FONT_FILE: %v", err)
+		}
+	}
+	if s.ExportPNG || s.PNGFrames {
+		if _, err := outlineFont(*s); err != nil {
+			return err
+		}
+		if s.PNGScale <= 0 {
+			return fmt.Errorf("PNG_SCALE must be greater than 0")
+		}
+		if s.PNGDPI < 0 {
+			return fmt.Errorf("PNG_DPI must be 0 (96 DPI times PNG_SCALE) or more")
+		}
+		if _, err := parseColor(s.PNGBackground); err != nil {
+			return fmt.Errorf("PNG_BACKGROUND: %v", err)
+		}
+	}
+	if (s.PNGFrames || s.ExportFCPXML) && s.PNGFPS <= 0 {
+		return fmt.Errorf("PNG_FPS must be greater than 0")
+	}
+	for _, path := range s.TXTTemplateFiles {
+		if _, err := loadTextTemplate(path); err != nil {
+			return fmt.Errorf("TXT_TEMPLATE_FILE %s: %v", path, err)
+		}
+	}
+	if s.ExportJSON || s.ExportCSV {
+		if _, err := rosterColumns(s.RosterFields); err != nil {
+			return fmt.Errorf("ROSTER_FIELDS: %v", err)
+		}
+	}
+	if s.ExportHTML {
+		if _, err := loadHTMLTemplate(*s); err != nil {
+			return fmt.Errorf("HTML_TEMPLATE_FILE: %v", err)
+		}
+	}
+	if s.ExportSRT {
+		if s.SRTNamesPerSecond <= 0 {
+			return fmt.Errorf("SRT_NAMES_PER_SECOND must be greater than 0")
+		}
+		if s.SRTNamesPerCue <= 0 {
+			return fmt.Errorf("SRT_NAMES_PER_CUE must be greater than 0")
+		}
+	}
+	for tier, n := range s.TierFontSizes {
+		if n <= 0 {
+			return fmt.Errorf("SVG_TIER_FONTSIZES for %q must be greater than 0", tier)
+		}
+	}
+	for tier, n := range s.TierLineHeights {
+		if n <= 0 {
+			return fmt.Errorf("SVG_TIER_LINEHEIGHTS for %q must be greater than 0", tier)
+		}
+	}
+	for tier, n := range s.TierColumns {
+		if n <= 0 {
+			return fmt.Errorf("SVG_TIER_COLUMNS for %q must be greater than 0", tier)
+		}
+	}
+	for tier, n := range s.TierSpacing {
+		if n < 0 {
+			return fmt.Errorf("SVG_TIER_SPACING for %q cannot be negative", tier)
+		}
+	}
+	if _, err := buildRules(*s); err != nil {
+		return err
+	}
+	if s.ExportStats && s.StatsTopCountries <= 0 {
+		return fmt.Errorf("STATS_TOP_COUNTRIES must be greater than 0")
+	}
+	if s.ReportingCurrency != "" {
+		if len(s.ReportingCurrency) != 3 || strings.Trim(s.ReportingCurrency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return fmt.Errorf("REPORTING_CURRENCY must be a three-letter currency code such as USD")
+		}
+		if s.RatesFile == "" {
+			return fmt.Errorf("REPORTING_CURRENCY needs a RATES_FILE")
+		}
+		if _, err := loadRateTable(s.RatesFile); err != nil {
+			return fmt.Errorf("RATES_FILE: %v", err)
+		}
+	}
+	if _, err := buildShippingRules(*s); err != nil {
+		return err
+	}
 	return nil
 }
+
+// ReferenceDate returns the date expiration and charge status decisions are
+// made against: AS_OF_DATE when it is set, otherwise now.
+func (s Settings) ReferenceDate(now time.Time) time.Time {
+	if t, err := parsePatreonDate(s.AsOfDate); err == nil && !t.IsZero() {
+		return t
+	}
+	return now
+}
@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadSettings_DefaultsWhenFileMissing(t *testing.T) {
@@ -167,3 +169,74 @@ ANOTHER_INVALID_LINE
 		t.Errorf("Expected Width to be 900, got %d", settings.Width)
 	}
 }
+
+func TestSettings_ReferenceDate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	if got := (Settings{}).ReferenceDate(now); !got.Equal(now) {
+		t.Errorf("expected now without AS_OF_DATE, got %v", got)
+	}
+	want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if got := (Settings{AsOfDate: "2024-05-01"}).ReferenceDate(now); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	s := defaultSettings()
+	if err := s.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.AsOfDate = "first of May"
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "AS_OF_DATE") {
+		t.Errorf("expected AS_OF_DATE validation error, got %v", err)
+	}
+}
+
+func TestLoadSettings_Tiers(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "settings.conf")
+	content := `
+TIER_ORDER=Gold, Silver ,Bronze
+TIER_ALIASES=Gold (legacy):Gold,Old Silver:Silver
+TXT_HIDE_TIERS=Staff
+SVG_HIDE_TIERS=Staff,Bronze
+`
+	if err := os.WriteFile(confPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp settings.conf: %v", err)
+	}
+	settings := LoadSettings(confPath)
+	if !reflect.DeepEqual(settings.TierOrder, []string{"Gold", "Silver", "Bronze"}) {
+		t.Errorf("unexpected TierOrder: %v", settings.TierOrder)
+	}
+	expectedAliases := map[string]string{"gold (legacy)": "Gold", "old silver": "Silver"}
+	if !reflect.DeepEqual(settings.TierAliases, expectedAliases) {
+		t.Errorf("unexpected TierAliases: %v", settings.TierAliases)
+	}
+	if !reflect.DeepEqual(settings.TXTHideTiers, []string{"Staff"}) || !reflect.DeepEqual(settings.SVGHideTiers, []string{"Staff", "Bronze"}) {
+		t.Errorf("unexpected hidden tiers: %v / %v", settings.TXTHideTiers, settings.SVGHideTiers)
+	}
+}
+
+func TestLoadSettings_TierSections(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "settings.conf")
+	content := `
+SVG_LAYOUT=sections
+SVG_HEADING_FONTSIZE=30
+SVG_TIER_HEADINGS=Gold:Gold Supporters
+SVG_TIER_FONTSIZES=Gold:24,Silver:abc
+SVG_TIER_COLUMNS=Gold:1
+SVG_TIER_COLORS=Gold:#ffd700|#ffffff
+`
+	if err := os.WriteFile(confPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp settings.conf: %v", err)
+	}
+	settings := LoadSettings(confPath)
+	if settings.SVGLayout != "sections" || settings.HeadingFontSize != 30 {
+		t.Errorf("unexpected layout settings: %q %d", settings.SVGLayout, settings.HeadingFontSize)
+	}
+	if settings.TierHeadings["gold"] != "Gold Supporters" {
+		t.Errorf("unexpected TierHeadings: %v", settings.TierHeadings)
+	}
+	if !reflect.DeepEqual(settings.TierFontSizes, map[string]int{"gold": 24}) {
+		t.Errorf("unexpected TierFontSizes: %v", settings.TierFontSizes)
+	}
+	if !reflect.DeepEqual(settings.TierColors["gold"], []string{"#ffd700", "#ffffff"}) {
+		t.Errorf("unexpected TierColors: %v", settings.TierColors)
+	}
+}
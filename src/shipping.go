@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// shippingAddress is a patron's postal address, cleaned up for printing.
+type shippingAddress struct {
+	Patron      Patron
+	Addressee   string
+	Street      []string // one entry per address line
+	City        string
+	State       string
+	Zip         string
+	Country     string // ISO code, e.g. US
+	CountryName string
+	Phone       string
+	// Missing lists the address fields a carrier needs that are empty.
+	Missing []string
+}
+
+// Complete reports whether the address has everything needed to post it.
+func (a shippingAddress) Complete() bool {
+	return len(a.Missing) == 0
+}
+
+// countriesWithoutPostcodes do not use postal codes, so an empty ZIP is fine.
+var countriesWithoutPostcodes = map[string]bool{
+	"AE": true, "AG": true, "AO": true, "BS": true, "BZ": true, "BO": true,
+	"FJ": true, "GH": true, "HK": true, "JM": true, "QA": true, "TT": true, "ZW": true,
+}
+
+// countriesWithStates need a state or province on the label.
+var countriesWithStates = map[string]bool{"US": true, "CA": true, "AU": true}
+
+// cleanAddressField trims a field and collapses runs of spaces inside it.
+func cleanAddressField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizeAddress cleans up a patron's address fields and records which
+// required ones are missing. The addressee falls back to the patron's name.
+func normalizeAddress(p Patron) shippingAddress {
+	a := shippingAddress{
+		Patron:      p,
+		Addressee:   cleanAddressField(p.Addressee),
+		City:        cleanAddressField(p.City),
+		State:       cleanAddressField(p.State),
+		Zip:         strings.ToUpper(cleanAddressField(p.Zip)),
+		Country:     strings.ToUpper(cleanAddressField(p.Country)),
+		CountryName: cleanAddressField(p.FullCountryName),
+		Phone:       cleanAddressField(p.Phone),
+	}
+	if a.Addressee == "" {
+		a.Addressee = cleanAddressField(p.Name)
+	}
+	// Patreon puts the second address line after a newline in Street.
+	for _, line := range strings.Split(strings.ReplaceAll(p.Street, "\r", ""), "\n") {
+		if line = cleanAddressField(line); line != "" {
+			a.Street = append(a.Street, line)
+		}
+	}
+	if len([]rune(a.State)) <= 3 {
+		a.State = strings.ToUpper(a.State)
+	}
+	if a.CountryName == "" {
+		a.CountryName = a.Country
+	}
+	// Spreadsheets drop the leading zeros of US ZIP codes such as 02134.
+	if a.Country == "US" && len(a.Zip) > 0 && len(a.Zip) < 5 && strings.Trim(a.Zip, "0123456789") == "" {
+		a.Zip = strings.Repeat("0", 5-len(a.Zip)) + a.Zip
+	}
+
+	if len(a.Street) == 0 {
+		a.Missing = append(a.Missing, "street")
+	}
+	if a.City == "" {
+		a.Missing = append(a.Missing, "city")
+	}
+	if a.State == "" && countriesWithStates[a.Country] {
+		a.Missing = append(a.Missing, "state")
+	}
+	if a.Zip == "" && !countriesWithoutPostcodes[a.Country] {
+		a.Missing = append(a.Missing, "zip")
+	}
+	if a.Country == "" {
+		a.Missing = append(a.Missing, "country")
+	}
+	return a
+}
+
+// buildShippingRules compiles SHIPPING_RULE; every rule must match.
+func buildShippingRules(settings Settings) ([]Rule, error) {
+	var rules []Rule
+	for _, expr := range settings.ShippingRules {
+		r, err := compileRule(expr, false, ReasonIncludeRule)
+		if err != nil {
+			return nil, fmt.Errorf("SHIPPING_RULE %q: %v", expr, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// shippingAddresses picks the patrons in SHIPPING_TIERS (or every tier) who
+// match every SHIPPING_RULE, and returns their addresses grouped by country
+// name. Within a country they keep the tier order. Addresses without a
+// country come last.
+func shippingAddresses(tiers []TierGroup, settings Settings, now time.Time) ([]shippingAddress, error) {
+	rules, err := buildShippingRules(settings)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(settings.ShippingTiers))
+	for _, name := range settings.ShippingTiers {
+		wanted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	var addresses []shippingAddress
+	for _, t := range tiers {
+		if len(wanted) > 0 && !wanted[strings.ToLower(t.Name)] {
+			continue
+		}
+	patrons:
+		for _, p := range t.Patrons {
+			for _, r := range rules {
+				if !r.Keeps(p, now) {
+					continue patrons
+				}
+			}
+			addresses = append(addresses, normalizeAddress(p))
+		}
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		a, b := addresses[i].countryKey(), addresses[j].countryKey()
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return a < b
+	})
+	return addresses, nil
+}
+
+// countryKey is the country name addresses are sorted and grouped by, so
+// "Germany" and "germany" go together.
+func (a shippingAddress) countryKey() string {
+	return strings.ToLower(a.CountryName)
+}
+
+// cityLine is the line of a label with the city, state and postal code.
+func (a shippingAddress) cityLine() string {
+	line := a.City
+	if a.State != "" {
+		if line != "" {
+			line += ","
+		}
+		line = strings.TrimSpace(line + " " + a.State)
+	}
+	return strings.TrimSpace(line + " " + a.Zip)
+}
+
+// labelLines are the lines printed on a label. The country is left off for
+// SHIPPING_HOME_COUNTRY, as domestic post does not need it.
+func (a shippingAddress) labelLines(homeCountry string) []string {
+	lines := append([]string{a.Addressee}, a.Street...)
+	lines = append(lines, a.cityLine())
+	if a.Country != "" && !strings.EqualFold(a.Country, homeCountry) {
+		lines = append(lines, strings.ToUpper(a.CountryName))
+	}
+	return lines
+}
+
+// writeShippingCSV writes every selected address, complete or not, in a
+// layout most carrier import tools accept. Missing lists what an incomplete
+// address still needs.
+func writeShippingCSV(path string, addresses []shippingAddress) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	header := []string{"Name", "Address Line 1", "Address Line 2", "City", "State", "Postal Code", "Country Code", "Country", "Phone", "Tier", "Missing"}
+	w.Write(header)
+	for _, a := range addresses {
+		var line1, line2 string
+		if len(a.Street) > 0 {
+			line1 = a.Street[0]
+			line2 = strings.Join(a.Street[1:], ", ")
+		}
+		w.Write([]string{a.Addressee, line1, line2, a.City, a.State, a.Zip, a.Country, a.CountryName, a.Phone,
+			strings.TrimSpace(a.Patron.Tier), strings.Join(a.Missing, ", ")})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Avery 5160 sheet geometry in points: US Letter, 3 columns of 10 labels of
+// 2 5/8" x 1".
+const (
+	labelSheetWidth  = 612
+	labelSheetHeight = 792
+	labelColumns     = 3
+	labelRows        = 10
+	labelWidth       = 189
+	labelHeight      = 72
+	labelLeft        = 13.5
+	labelTop         = 36
+	labelPitchX      = 198
+	labelPitchY      = 72
+	labelPadding     = 9
+	labelFontSize    = 9
+	labelLineHeight  = 10.5
+)
+
+// writeShippingLabels writes the complete addresses onto Avery 5160 sheets,
+// one SVG per sheet. A single sheet is written to outputPath; more are
+// numbered like paginated credits (shipping_labels_001.svg, ...). It returns the
+// files written.
+func writeShippingLabels(outputPath string, addresses []shippingAddress, settings Settings) ([]string, error) {
+	var complete []shippingAddress
+	for _, a := range addresses {
+		if a.Complete() {
+			complete = append(complete, a)
+		}
+	}
+	perSheet := labelColumns * labelRows
+	sheets := (len(complete) + perSheet - 1) / perSheet
+	if sheets == 0 {
+		return nil, nil
+	}
+	var paths []string
+	for i := 0; i < sheets; i++ {
+		path := outputPath
+		if sheets > 1 {
+			ext := filepath.Ext(outputPath)
+			path = fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(outputPath, ext), i+1, ext)
+		}
+		end := (i + 1) * perSheet
+		if end > len(complete) {
+			end = len(complete)
+		}
+		if err := writeLabelSheet(path, complete[i*perSheet:end], settings); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeLabelSheet(path string, addresses []shippingAddress, settings Settings) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	measure := approxMeasurer{}
+	fontFamily := escapeXML(settings.FontFamily)
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="8.5in" height="11in" viewBox="0 0 %d %d">`, labelSheetWidth, labelSheetHeight)
+	writeSVGMetadata(w, settings)
+	fmt.Fprintln(w)
+	for i, a := range addresses {
+		x := labelLeft + float64(i%labelColumns*labelPitchX)
+		y := float64(labelTop + i/labelColumns*labelPitchY)
+		lines := a.labelLines(settings.ShippingHomeCountry)
+		// Centre the block of lines vertically on the label.
+		baseline := y + (labelHeight-float64(len(lines))*labelLineHeight)/2 + labelFontSize
+		for j, line := range lines {
+			fit := ""
+			maxWidth := float64(labelWidth - labelPadding*2)
+			if measure.textWidth(line, labelFontSize) > maxWidth {
+				fit = fmt.Sprintf(` textLength="%s" lengthAdjust="spacingAndGlyphs"`, formatNumber(maxWidth))
+			}
+			fmt.Fprintf(w, `<text x="%s" y="%s" font-family="%s" font-size="%d"%s>%s</text>`,
+				formatNumber(x+labelPadding), formatNumber(baseline+float64(j)*labelLineHeight),
+				fontFamily, labelFontSize, fit, escapeXML(line))
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprint(w, `</svg>`)
+	return w.Flush()
+}
+
+// printShippingSummary prints the reference date the addresses were selected
+// on, how many packages go to each country and the addresses that still need
+// fixing before they can be posted.
+func printShippingSummary(w io.Writer, addresses []shippingAddress, referenceDate string) {
+	fmt.Fprintf(w, "Shipping to %d patrons as of %s:\n", len(addresses), referenceDate)
+	var incomplete []shippingAddress
+	for i := 0; i < len(addresses); {
+		j := i
+		for j < len(addresses) && addresses[j].countryKey() == addresses[i].countryKey() {
+			j++
+		}
+		country := addresses[i].CountryName
+		if country == "" {
+			country = "(no country)"
+		}
+		fmt.Fprintf(w, "  %s: %d\n", country, j-i)
+		i = j
+	}
+	for _, a := range addresses {
+		if !a.Complete() {
+			incomplete = append(incomplete, a)
+		}
+	}
+	if len(incomplete) > 0 {
+		fmt.Fprintf(w, "Incomplete addresses (no label printed): %d\n", len(incomplete))
+		for _, a := range incomplete {
+			fmt.Fprintf(w, "  - %s (%s): missing %s\n", a.Patron.Name, strings.TrimSpace(a.Patron.Tier), strings.Join(a.Missing, ", "))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	a := normalizeAddress(Patron{
+		Name:            "Alice",
+		Street:          "  12  Main St\r\nApt 4 ",
+		City:            " Boston ",
+		State:           "ma",
+		Zip:             "2134",
+		Country:         "us",
+		FullCountryName: "United States",
+	})
+	if a.Addressee != "Alice" {
+		t.Errorf("Addressee = %q, want the patron name", a.Addressee)
+	}
+	if !reflect.DeepEqual(a.Street, []string{"12 Main St", "Apt 4"}) {
+		t.Errorf("Street = %q", a.Street)
+	}
+	if a.State != "MA" || a.Zip != "02134" || a.Country != "US" {
+		t.Errorf("State, Zip, Country = %q, %q, %q", a.State, a.Zip, a.Country)
+	}
+	if !a.Complete() {
+		t.Errorf("Missing = %v, want none", a.Missing)
+	}
+	want := []string{"Alice", "12 Main St", "Apt 4", "Boston, MA 02134"}
+	if got := a.labelLines("US"); !reflect.DeepEqual(got, want) {
+		t.Errorf("labelLines(US) = %q, want %q", got, want)
+	}
+	if got := a.labelLines("DE"); got[len(got)-1] != "UNITED STATES" {
+		t.Errorf("labelLines(DE) = %q, want the country last", got)
+	}
+
+	for _, tc := range []struct {
+		p       Patron
+		missing []string
+	}{
+		{Patron{Name: "Bob", City: "Austin", Country: "US"}, []string{"street", "state", "zip"}},
+		{Patron{Name: "Carol", Street: "1 Queen's Rd", City: "Hong Kong", Country: "HK"}, nil},
+		{Patron{Name: "Dan", Street: "Hauptstr. 1", Zip: "10115"}, []string{"city", "country"}},
+	} {
+		if got := normalizeAddress(tc.p).Missing; !reflect.DeepEqual(got, tc.missing) {
+			t.Errorf("%s: Missing = %v, want %v", tc.p.Name, got, tc.missing)
+		}
+	}
+}
+
+func TestShippingAddresses(t *testing.T) {
+	address := func(name, tier, country, countryName, pledge string) Patron {
+		return Patron{Name: name, Tier: tier, Street: "1 Road", City: "Town", State: "ST", Zip: "12345",
+			Country: country, FullCountryName: countryName, PledgeAmount: pledge}
+	}
+	tiers := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{address("Alice", "Gold", "US", "United States", "20"), address("Bob", "Gold", "", "", "20")}},
+		{Name: "Silver", Patrons: []Patron{address("Carol", "Silver", "DE", "Germany", "10"), address("Dan", "Silver", "US", "United States", "8")}},
+		{Name: "Bronze", Patrons: []Patron{address("Erin", "Bronze", "CA", "Canada", "5")}},
+	}
+	settings := defaultSettings()
+	settings.ShippingTiers = []string{"gold", "Silver"}
+	settings.ShippingRules = []string{"pledge >= 10"}
+	addresses, err := shippingAddresses(tiers, settings, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range addresses {
+		names = append(names, a.Patron.Name)
+	}
+	if want := []string{"Carol", "Alice", "Bob"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("addresses = %v, want %v", names, want)
+	}
+}
+
+func TestPrintShippingSummary(t *testing.T) {
+	address := func(name, countryName string) Patron {
+		return Patron{Name: name, Tier: "Gold", Street: "1 Road", City: "Town", Zip: "12345",
+			Country: "DE", FullCountryName: countryName}
+	}
+	tiers := []TierGroup{{Name: "Gold", Patrons: []Patron{
+		address("Alice", "Germany"), address("Bob", "Austria"), address("Carol", "germany"),
+	}}}
+	addresses, err := shippingAddresses(tiers, defaultSettings(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	printShippingSummary(&out, addresses, "2024-05-01T00:00:00Z")
+	want := "Shipping to 3 patrons as of 2024-05-01T00:00:00Z:\n  Austria: 1\n  Germany: 2\n"
+	if out.String() != want {
+		t.Errorf("summary = %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteShippingLabels(t *testing.T) {
+	var addresses []shippingAddress
+	for i := 0; i < 31; i++ {
+		addresses = append(addresses, normalizeAddress(Patron{Name: "Patron & Co", Street: "1 Road", City: "Town", Zip: "1000", Country: "NL", FullCountryName: "Netherlands"}))
+	}
+	addresses = append(addresses, normalizeAddress(Patron{Name: "No Address"}))
+	dir := t.TempDir()
+	paths, err := writeShippingLabels(filepath.Join(dir, "labels.svg"), addresses, defaultSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "labels_001.svg"), filepath.Join(dir, "labels_002.svg")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	data, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	if n := strings.Count(svg, "Patron &amp; Co"); n != 1 {
+		t.Errorf("second sheet has %d labels, want 1:\n%s", n, svg)
+	}
+	if strings.Contains(svg, "No Address") {
+		t.Error("incomplete address was printed on a label")
+	}
+	if !strings.Contains(svg, `width="8.5in" height="11in"`) {
+		t.Errorf("sheet is not US Letter:\n%s", svg)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// moneyTotals holds amounts by currency, in minor units. Patrons pay in
+// their own currency, so without a REPORTING_CURRENCY to convert to, totals
+// are kept apart rather than added up.
+type moneyTotals map[string]int64
+
+func (m moneyTotals) add(money Money) {
+	m[money.Currency] += money.Minor
+}
+
+func (m moneyTotals) currencies() []string {
+	currencies := make([]string, 0, len(m))
+	for c := range m {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// String lists the totals by currency code, as "50.00 EUR, 420.00 USD".
+func (m moneyTotals) String() string {
+	if len(m) == 0 {
+		return "0"
+	}
+	var parts []string
+	for _, c := range m.currencies() {
+		parts = append(parts, Money{Minor: m[c], Currency: c}.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MarshalJSON writes the totals as {"USD": 420.00}, keeping the exact amount.
+func (m moneyTotals) MarshalJSON() ([]byte, error) {
+	out := make(map[string]json.Number, len(m))
+	for c, minor := range m {
+		money := Money{Minor: minor, Currency: c}
+		out[c] = json.Number(strings.TrimSuffix(money.String(), " "+c))
+	}
+	return json.Marshal(out)
+}
+
+// monthlyTotals adds up monthly amounts exactly. Annual pledges count for a
+// twelfth of their amount, so sums are kept in twelfths of a minor unit and
+// only rounded when read.
+type monthlyTotals map[string]int64
+
+func (m monthlyTotals) add(pledge Money, frequency ChargeFrequency) {
+	if frequency == ChargeFrequencyAnnual {
+		m[pledge.Currency] += pledge.Minor
+	} else {
+		m[pledge.Currency] += pledge.Minor * 12
+	}
+}
+
+// totals rounds the sums to whole minor units, divided by n (1 for the total
+// itself, the patron count for an average).
+func (m monthlyTotals) totals(n int64) moneyTotals {
+	out := moneyTotals{}
+	if n <= 0 {
+		return out
+	}
+	for c, twelfths := range m {
+		d := 12 * n
+		out[c] = (twelfths + d/2) / d
+	}
+	return out
+}
+
+// tierStats is the headcount and revenue of one tier.
+type tierStats struct {
+	Name     string      `json:"name"`
+	Patrons  int         `json:"patrons"`
+	MRR      moneyTotals `json:"mrr"`
+	Lifetime moneyTotals `json:"lifetime"`
+}
+
+// countryStats is how many paying patrons live in a country.
+type countryStats struct {
+	Country string `json:"country"`
+	Patrons int    `json:"patrons"`
+}
+
+// statsReport is written to stats.md and stats.json.
+type statsReport struct {
+	ReferenceDate string         `json:"reference_date"`
+	SourceFile    string         `json:"source_file"`
+	Paying        int            `json:"paying_patrons"`
+	Free          int            `json:"free_members"`
+	FreeRatio     float64        `json:"free_per_paying"`
+	FreeTrials    int            `json:"free_trials"`
+	MRR           moneyTotals    `json:"mrr"`
+	AveragePledge moneyTotals    `json:"average_monthly_pledge"`
+	Lifetime      moneyTotals    `json:"lifetime"`
+	Tiers         []tierStats    `json:"tiers"`
+	TopCountries  []countryStats `json:"top_countries"`
+}
+
+// isFreeMember reports whether a patron follows for free rather than paying.
+func isFreeMember(p Patron) bool {
+	return yesNo(p.FreeMember) || strings.Contains(p.Tier, "Free")
+}
+
+// buildStats computes the report from every parsed patron and the tiers of
+// the patrons kept by the rules. Monthly recurring revenue (MRR) counts
+// monthly and per-creation pledges as they are and annual pledges as a
+// twelfth. Patrons still in a free trial haven't paid yet and are left out
+// of MRR and the average pledge, but are counted in their tier. Amounts are
+// converted with conv when it is not nil.
+func buildStats(patrons []Patron, tiers []TierGroup, summary runSummary, topCountries int, conv *currencyConverter) statsReport {
+	report := statsReport{
+		ReferenceDate: summary.ReferenceDate,
+		SourceFile:    summary.SourceFile,
+		Lifetime:      moneyTotals{},
+	}
+	for _, p := range patrons {
+		if isFreeMember(p) {
+			report.Free++
+		}
+	}
+
+	mrr := monthlyTotals{}
+	payingByCurrency := map[string]int64{}
+	countries := map[string]int{}
+	for _, t := range tiers {
+		tier := tierStats{Name: t.Name, Patrons: len(t.Patrons), Lifetime: moneyTotals{}}
+		tierMRR := monthlyTotals{}
+		for _, p := range t.Patrons {
+			v := p.Typed()
+			pledge, _ := conv.convert(v.PledgeAmount)
+			lifetime, _ := conv.convert(v.LifetimeAmount)
+			tier.Lifetime.add(lifetime)
+			report.Lifetime.add(lifetime)
+			if isFreeMember(p) {
+				continue
+			}
+			report.Paying++
+			country := strings.TrimSpace(p.FullCountryName)
+			if country == "" {
+				country = strings.TrimSpace(p.Country)
+			}
+			if country == "" {
+				country = "Unknown"
+			}
+			countries[country]++
+			if yesNo(p.FreeTrial) {
+				report.FreeTrials++
+				continue
+			}
+			tierMRR.add(pledge, v.ChargeFrequency)
+			mrr.add(pledge, v.ChargeFrequency)
+			payingByCurrency[pledge.Currency]++
+		}
+		tier.MRR = tierMRR.totals(1)
+		report.Tiers = append(report.Tiers, tier)
+	}
+	report.MRR = mrr.totals(1)
+	report.AveragePledge = moneyTotals{}
+	for c, n := range payingByCurrency {
+		report.AveragePledge[c] = monthlyTotals{c: mrr[c]}.totals(n)[c]
+	}
+	if report.Paying > 0 {
+		report.FreeRatio = float64(report.Free) / float64(report.Paying)
+	}
+
+	for country, n := range countries {
+		report.TopCountries = append(report.TopCountries, countryStats{Country: country, Patrons: n})
+	}
+	sort.Slice(report.TopCountries, func(i, j int) bool {
+		a, b := report.TopCountries[i], report.TopCountries[j]
+		if a.Patrons != b.Patrons {
+			return a.Patrons > b.Patrons
+		}
+		return a.Country < b.Country
+	})
+	if len(report.TopCountries) > topCountries {
+		report.TopCountries = report.TopCountries[:topCountries]
+	}
+	return report
+}
+
+// writeStatsMarkdown writes the report as Markdown tables.
+func writeStatsMarkdown(w io.Writer, r statsReport) {
+	fmt.Fprintln(w, "# Patreon statistics")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Reference date %s, from %s.\n\n", dateOnly(r.ReferenceDate), r.SourceFile)
+	fmt.Fprintln(w, "| | |")
+	fmt.Fprintln(w, "|---|---|")
+	fmt.Fprintf(w, "| Paying patrons | %d |\n", r.Paying)
+	fmt.Fprintf(w, "| Free members | %d (%.2f per paying patron) |\n", r.Free, r.FreeRatio)
+	fmt.Fprintf(w, "| In a free trial | %d |\n", r.FreeTrials)
+	fmt.Fprintf(w, "| Monthly recurring revenue | %s |\n", r.MRR)
+	fmt.Fprintf(w, "| Average monthly pledge | %s |\n", r.AveragePledge)
+	fmt.Fprintf(w, "| Lifetime revenue | %s |\n", r.Lifetime)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "## Tiers")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Tier | Patrons | MRR | Lifetime |")
+	fmt.Fprintln(w, "|---|---:|---:|---:|")
+	for _, t := range r.Tiers {
+		fmt.Fprintf(w, "| %s | %d | %s | %s |\n", markdownCell(t.Name), t.Patrons, t.MRR, t.Lifetime)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "## Top countries")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Country | Patrons |")
+	fmt.Fprintln(w, "|---|---:|")
+	for _, c := range r.TopCountries {
+		fmt.Fprintf(w, "| %s | %d |\n", markdownCell(c.Country), c.Patrons)
+	}
+}
+
+// markdownCell keeps a value from breaking out of its table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// writeStatsFiles writes stats.md and stats.json.
+func writeStatsFiles(mdPath, jsonPath string, r statsReport) error {
+	f, err := os.Create(mdPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	writeStatsMarkdown(w, r)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	jf, err := os.Create(jsonPath)
+	if err != nil {
+		return err
+	}
+	defer jf.Close()
+	enc := json.NewEncoder(jf)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBuildStats(t *testing.T) {
+	gold := []Patron{
+		{Name: "Alice", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD", ChargeFrequency: "monthly", LifetimeAmount: "120.00", FullCountryName: "United States"},
+		{Name: "Bob", Tier: "Gold", PledgeAmount: "100.00", Currency: "USD", ChargeFrequency: "annual", LifetimeAmount: "100.00", FullCountryName: "Germany"},
+		{Name: "Carol", Tier: "Gold", PledgeAmount: "10.00", Currency: "USD", FreeTrial: "Yes", FullCountryName: "United States"},
+	}
+	silver := []Patron{
+		{Name: "Dan", Tier: "Silver", PledgeAmount: "500", Currency: "JPY", LifetimeAmount: "1500", Country: "JP"},
+	}
+	free := Patron{Name: "Erin", Tier: "Free", FreeMember: "Yes"}
+	patrons := append(append(append([]Patron{}, gold...), silver...), free)
+	tiers := []TierGroup{{Name: "Gold", Patrons: gold}, {Name: "Silver", Patrons: silver}}
+
+	r := buildStats(patrons, tiers, runSummary{ReferenceDate: "2024-05-01T00:00:00Z", SourceFile: "pledges.csv"}, 2, nil)
+	if r.Paying != 4 || r.Free != 1 || r.FreeTrials != 1 {
+		t.Errorf("paying, free, trials = %d, %d, %d; want 4, 1, 1", r.Paying, r.Free, r.FreeTrials)
+	}
+	// 10.00 + 100.00/12 = 18.33; the trial pays nothing yet.
+	if got := r.MRR.String(); got != "500 JPY, 18.33 USD" {
+		t.Errorf("MRR = %s", got)
+	}
+	if got := r.AveragePledge.String(); got != "500 JPY, 9.17 USD" {
+		t.Errorf("average pledge = %s", got)
+	}
+	if got := r.Tiers[0].Lifetime.String(); got != "220.00 USD" || r.Tiers[0].Patrons != 3 {
+		t.Errorf("Gold = %+v", r.Tiers[0])
+	}
+	if len(r.TopCountries) != 2 || r.TopCountries[0] != (countryStats{Country: "United States", Patrons: 2}) || r.TopCountries[1].Country != "Germany" {
+		t.Errorf("top countries = %+v", r.TopCountries)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"mrr":{"JPY":500,"USD":18.33}`) {
+		t.Errorf("JSON = %s", data)
+	}
+
+	var md bytes.Buffer
+	writeStatsMarkdown(&md, r)
+	for _, want := range []string{"| Monthly recurring revenue | 500 JPY, 18.33 USD |", "| Gold | 3 | 18.33 USD | 220.00 USD |", "| Free members | 1 (0.25 per paying patron) |"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown is missing %q:\n%s", want, md.String())
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// ExportASS writes the credits as an Advanced SubStation Alpha subtitle file.
+// Every name is placed where the SVG layout puts it, on a SVG_WIDTH x
+// SVG_HEIGHT screen, and scrolls up with the SVG_SCROLL_* timing. Subtitle
+// renderers only move text at a steady speed, so SVG_SCROLL_EASING is ignored.
+func ExportASS(tiers []TierGroup, outputPath string, settings Settings) error {
+	layout := buildLayout(tiers, settings)
+	if err := overflowError(layout); err != nil {
+		return err
+	}
+	timeline := newScrollTimeline(layout, settings)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	fmt.Fprintln(w, "[Script Info]")
+	fmt.Fprintln(w, "; Patreon credits")
+	if settings.AsOfDate != "" {
+		fmt.Fprintf(w, "; Reference date: %s\n", settings.AsOfDate)
+	}
+	fmt.Fprintln(w, "ScriptType: v4.00+")
+	fmt.Fprintf(w, "PlayResX: %d\n", layout.Width)
+	fmt.Fprintf(w, "PlayResY: %d\n", settings.CanvasHeight)
+	fmt.Fprintln(w, "WrapStyle: 2")
+	fmt.Fprintln(w, "ScaledBorderAndShadow: yes")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "[V4+ Styles]")
+	fmt.Fprintln(w, "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding")
+	// Outline 1 matches the SVG's 2px stroke, half of which is behind the fill.
+	fmt.Fprintf(w, "Style: Default,%s,%d,&H00FFFFFF,&H00FFFFFF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,1,0,2,0,0,0,1\n",
+		assFontName(settings.FontFamily), settings.FontSize)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "[Events]")
+	fmt.Fprintln(w, "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text")
+
+	end := assTime(timeline.Total())
+	for _, item := range layout.Items {
+		c, err := assColor(item.Color)
+		if err != nil {
+			return fmt.Errorf("color for %q: %v", item.Text, err)
+		}
+		// \an2 anchors the bottom centre of the text, close to the SVG's
+		// centred baseline.
+		position := fmt.Sprintf(`\pos(%d,%d)`, item.X, item.Y)
+		if timeline.Distance > 0 {
+			position = fmt.Sprintf(`\move(%d,%d,%d,%d,%d,%d)`, item.X, item.Y, item.X, item.Y-timeline.Distance,
+				int(math.Round(timeline.HoldStart*1000)), int(math.Round((timeline.HoldStart+timeline.Duration)*1000)))
+		}
+		bold := ""
+		if item.Heading {
+			bold = `\b1`
+		}
+		fmt.Fprintf(w, "Dialogue: 0,%s,%s,Default,,0,0,0,,{\\an2%s\\fs%d\\c%s%s}%s\n",
+			assTime(0), end, position, item.FontSize, c, bold, assText(item.Text))
+	}
+	return w.Flush()
+}
+
+// ExportSRT writes the credits as plain SRT subtitles for players that do not
+// support ASS. Each cue shows up to SRT_NAMES_PER_CUE names of one tier, under
+// the tier heading in sections layout, for as long as SRT_NAMES_PER_SECOND allows.
+func ExportSRT(tiers []TierGroup, outputPath string, settings Settings) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	cue := 0
+	start := 0.0
+	for _, tier := range tiers {
+		heading := ""
+		if settings.SVGLayout == "sections" {
+			heading = tierSectionStyle(settings, tier.Name).Heading
+		}
+		for i := 0; i < len(tier.Patrons); i += settings.SRTNamesPerCue {
+			end := i + settings.SRTNamesPerCue
+			if end > len(tier.Patrons) {
+				end = len(tier.Patrons)
+			}
+			var lines []string
+			if heading != "" {
+				lines = append(lines, heading)
+			}
+			for _, p := range tier.Patrons[i:end] {
+				lines = append(lines, p.Name)
+			}
+			duration := float64(end-i) / settings.SRTNamesPerSecond
+			cue++
+			fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", cue, srtTime(start), srtTime(start+duration), strings.Join(lines, "\n"))
+			start += duration
+		}
+	}
+	return w.Flush()
+}
+
+// assTime formats seconds as H:MM:SS.cc.
+func assTime(seconds float64) string {
+	cs := int(math.Round(seconds * 100))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+// srtTime formats seconds as HH:MM:SS,mmm.
+func srtTime(seconds float64) string {
+	ms := int(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// assColor converts a colour to ASS's &HBBGGRR& form.
+func assColor(s string) (string, error) {
+	c, err := parseColor(s)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("&H%02X%02X%02X&", c.B, c.G, c.R), nil
+}
+
+// assFontName returns the first family of a CSS font-family list.
+func assFontName(family string) string {
+	first := strings.Split(family, ",")[0]
+	first = strings.Trim(strings.TrimSpace(first), `"'`)
+	if first == "" {
+		return "Arial"
+	}
+	return first
+}
+
+// assText keeps names from being read as override tags ({...}) or escapes
+// such as \N, by swapping braces for parentheses and putting a zero-width
+// space after each backslash.
+func assText(s string) string {
+	return strings.NewReplacer("{", "(", "}", ")", `\`, "\\\u200b").Replace(s)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportASS(t *testing.T) {
+	settings := scrollTestSettings()
+	settings.RandomizeSVGColors = false
+	settings.ColumnColors = []string{"#ff8800"}
+	settings.UserColorMap = map[string]string{"gold patron 1": "#0000ff"}
+	settings.ScrollHoldStart = 2
+	settings.ScrollHoldEnd = 1
+	settings.AsOfDate = "2024-05-01T00:00:00Z"
+	path := filepath.Join(t.TempDir(), "all_names.ass")
+	if err := ExportASS([]TierGroup{namedTier("Gold", 20)}, path, settings); err != nil {
+		t.Fatalf("ExportASS failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+
+	layout := buildLayout([]TierGroup{namedTier("Gold", 20)}, settings)
+	distance := layout.Height - settings.CanvasHeight
+	for _, want := range []string{
+		"; Reference date: 2024-05-01T00:00:00Z\n",
+		"PlayResX: 800\n",
+		"PlayResY: 200\n",
+		"Style: Default,Trebuchet MS,16,",
+		// The heading, bold and centred, moving up during the 20s scroll.
+		`Dialogue: 0,0:00:00.00,0:00:23.00,Default,,0,0,0,,{\an2\move(400,34,400,` + strconv.Itoa(34-distance) + `,2000,22000)\fs24\c&HFFFFFF&\b1}Gold`,
+		`\c&H0088FF&}Gold patron 0`,
+		`\c&HFF0000&}Gold patron 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportSRT(t *testing.T) {
+	settings := pagingTestSettings()
+	settings.SRTNamesPerSecond = 2
+	settings.SRTNamesPerCue = 3
+	settings.TierHeadings = map[string]string{"gold": "Gold Patrons"}
+	path := filepath.Join(t.TempDir(), "all_names.srt")
+	tiers := []TierGroup{namedTier("Gold", 4), namedTier("Silver", 1)}
+	if err := ExportSRT(tiers, path, settings); err != nil {
+		t.Fatalf("ExportSRT failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `1
+00:00:00,000 --> 00:00:01,500
+Gold Patrons
+Gold patron 0
+Gold patron 1
+Gold patron 2
+
+2
+00:00:01,500 --> 00:00:02,000
+Gold Patrons
+Gold patron 3
+
+3
+00:00:02,000 --> 00:00:02,500
+Silver
+Silver patron 0
+
+`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestAssText(t *testing.T) {
+	if got := assText(`{\b1}Bob\N`); got != "(\\\u200bb1)Bob\\\u200bN" {
+		t.Errorf("unexpected escape: %q", got)
+	}
+	if got := assTime(3725.456); got != "1:02:05.46" {
+		t.Errorf("assTime = %q", got)
+	}
+	if got := srtTime(3725.456); got != "01:02:05,456" {
+		t.Errorf("srtTime = %q", got)
+	}
+}
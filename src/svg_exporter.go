@@ -2,79 +2,102 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
-	"sort"
 	"strings"
 )
 
-func ExportNamesSVG(names []string, outputPath string, settings Settings) error {
-	var (
-		width        = settings.Width
-		margin       = settings.Margin
-		colGap       = settings.ColGap
-		fontSize     = settings.FontSize
-		lineHeight   = settings.LineHeight
-		columns      = settings.Columns
-		fontFamily   = settings.FontFamily
-		columnColors = settings.ColumnColors
-	)
-	r := rand.New(rand.NewSource(int64(len(names))))
-	// Sort names alphabetically
-	sort.Slice(names, func(i, j int) bool {
-		return strings.ToLower(names[i]) < strings.ToLower(names[j])
-	})
-
-	// Split names into columns
-	nPerCol := (len(names) + columns - 1) / columns
-	colNames := make([][]string, columns)
-	for i, name := range names {
-		col := i / nPerCol
-		if col >= columns {
-			col = columns - 1
-		}
-		colNames[col] = append(colNames[col], name)
+// ExportTiersSVG writes the names of every tier, in tier order. With
+// SVG_LAYOUT=sections each tier gets its own heading and style; otherwise all
+// names share one block of columns.
+//
+// With SVG_FIT=true the font size and column count are chosen so every name
+// fits a SVG_WIDTH x SVG_HEIGHT canvas, and the choice is stored as metadata.
+//
+// With SVG_MAX_PAGE_HEIGHT or SVG_MAX_NAMES_PER_PAGE set the names are split
+// across numbered pages (all_names_001.svg, all_names_002.svg, ...). It
+// returns the paths written.
+func ExportTiersSVG(tiers []TierGroup, outputPath string, settings Settings) ([]string, error) {
+	pages, err := layoutPages(tiers, settings)
+	if settings.Fit {
+		fmt.Printf("SVG fit mode chose %s\n", pages.Fit)
 	}
-
-	// Calculate SVG height
-	maxColLen := 0
-	for _, col := range colNames {
-		if len(col) > maxColLen {
-			maxColLen = len(col)
+	if err != nil {
+		return nil, err
+	}
+	paths := pages.paths(outputPath)
+	for i, layout := range pages.Layouts {
+		if err := writeLayoutSVGFile(layout, paths[i], pages.Settings, pages.attrs(i)...); err != nil {
+			return paths[:i], err
 		}
 	}
-	height := margin*2 + maxColLen*lineHeight
+	return paths, nil
+}
 
-	// Prepare SVG file
+func writeLayoutSVGFile(layout creditsLayout, outputPath string, settings Settings, attrs ...string) error {
+	if err := overflowError(layout); err != nil {
+		return err
+	}
 	f, err := os.Create(outputPath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	writeLayoutSVG(f, layout, settings, attrs...)
+	return nil
+}
 
-	// SVG header
-	fmt.Fprintf(f, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, width, height)
-	fmt.Fprintf(f, `<rect width="100%%" height="100%%" fill="none"/>`)
-
-	// Column positions
-	colWidth := (width - margin*2 - colGap*(columns-1)) / columns
+// writeLayoutSVG renders a computed layout as a standalone SVG document.
+func writeLayoutSVG(w io.Writer, layout creditsLayout, settings Settings, attrs ...string) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`, layout.Width, layout.Height)
+	writeSVGMetadata(w, settings, attrs...)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="none"/>`)
+	writeSVGItems(w, layout.Items, 0, settings)
+	fmt.Fprint(w, `</svg>`)
+}
 
-	for colIdx, col := range colNames {
-		x := margin + colIdx*(colWidth+colGap) + colWidth/2
-		for rowIdx, name := range col {
-			y := margin + fontSize + rowIdx*lineHeight
-			color := getColorForName(colIdx, columnColors, settings.RandomizeSVGColors, r, name, settings.UserColorMap)
-			fmt.Fprintf(f,
-				`<text x="%d" y="%d" font-family="%s" font-size="%d" fill="none" stroke="#000" stroke-width="2" paint-order="stroke" text-anchor="middle">%s</text>`,
-				x, y, fontFamily, fontSize, escapeXML(name))
-			fmt.Fprintf(f,
-				`<text x="%d" y="%d" font-family="%s" font-size="%d" fill="%s" stroke="none" text-anchor="middle">%s</text>`,
-				x, y, fontFamily, fontSize, color, escapeXML(name))
+// writeSVGItems writes each item twice: a black outline behind a coloured fill,
+// so names stay readable on any background. Items are moved up by offsetY.
+func writeSVGItems(w io.Writer, items []layoutItem, offsetY int, settings Settings) {
+	fontFamily := escapeXML(settings.FontFamily)
+	for _, item := range items {
+		weight := ""
+		if item.Heading {
+			weight = ` font-weight="bold"`
 		}
+		y := item.Y - offsetY
+		fmt.Fprintf(w,
+			`<text x="%d" y="%d" font-family="%s" font-size="%d"%s fill="none" stroke="#000" stroke-width="2" paint-order="stroke" text-anchor="middle">%s</text>`,
+			item.X, y, fontFamily, item.FontSize, weight, escapeXML(item.Text))
+		fmt.Fprintf(w,
+			`<text x="%d" y="%d" font-family="%s" font-size="%d"%s fill="%s" stroke="none" text-anchor="middle">%s</text>`,
+			item.X, y, fontFamily, item.FontSize, weight, item.Color, escapeXML(item.Text))
 	}
+}
 
-	fmt.Fprint(f, `</svg>`)
-	return nil
+// overflowError reports the names that did not fit when SVG_OVERFLOW=fail.
+func overflowError(layout creditsLayout) error {
+	if len(layout.Overflow) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d name(s) are too wide for their column: %s", len(layout.Overflow), strings.Join(layout.Overflow, ", "))
+}
+
+// writeSVGMetadata records the reference date of the run, plus any extra
+// name/value attribute pairs, so a credits roll can be traced back to its run.
+func writeSVGMetadata(w io.Writer, settings Settings, attrs ...string) {
+	if settings.AsOfDate == "" && len(attrs) == 0 {
+		return
+	}
+	fmt.Fprint(w, `<metadata><credits xmlns="https://github.com/jothebuzzyard/patreon-pledge-parser"`)
+	if settings.AsOfDate != "" {
+		fmt.Fprintf(w, ` reference-date="%s"`, escapeXML(settings.AsOfDate))
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(w, ` %s="%s"`, attrs[i], escapeXML(attrs[i+1]))
+	}
+	fmt.Fprint(w, `/></metadata>`)
 }
 
 func getColorForName(colIdx int, columnColors []string, randomize bool, r *rand.Rand, name string, userColorMap map[string]string) string {
@@ -2,11 +2,22 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
-func TestExportNamesSVG_Basic(t *testing.T) {
+// exportFlatSVG writes names as one tier in the flat layout.
+func exportFlatSVG(names []string, outputPath string, settings Settings) error {
+	tier := TierGroup{Name: "Patrons"}
+	for _, name := range names {
+		tier.Patrons = append(tier.Patrons, Patron{Name: name})
+	}
+	_, err := ExportTiersSVG([]TierGroup{tier}, outputPath, settings)
+	return err
+}
+
+func TestExportTiersSVG_FlatBasic(t *testing.T) {
 	names := []string{"Alice", "Bob", "Charlie"}
 	settings := Settings{
 		Width:        400,
@@ -24,9 +35,9 @@ func TestExportNamesSVG_Basic(t *testing.T) {
 	}
 	defer os.Remove(tmpfile.Name())
 
-	err = ExportNamesSVG(names, tmpfile.Name(), settings)
+	err = exportFlatSVG(names, tmpfile.Name(), settings)
 	if err != nil {
-		t.Fatalf("ExportNamesSVG failed: %v", err)
+		t.Fatalf("ExportTiersSVG failed: %v", err)
 	}
 
 	data, err := os.ReadFile(tmpfile.Name())
@@ -44,7 +55,7 @@ func TestExportNamesSVG_Basic(t *testing.T) {
 	}
 }
 
-func TestExportNamesSVG_ColumnColors(t *testing.T) {
+func TestExportTiersSVG_FlatColumnColors(t *testing.T) {
 	names := []string{"A", "B", "C", "D"}
 	settings := Settings{
 		Width:        400,
@@ -62,9 +73,9 @@ func TestExportNamesSVG_ColumnColors(t *testing.T) {
 	}
 	defer os.Remove(tmpfile.Name())
 
-	err = ExportNamesSVG(names, tmpfile.Name(), settings)
+	err = exportFlatSVG(names, tmpfile.Name(), settings)
 	if err != nil {
-		t.Fatalf("ExportNamesSVG failed: %v", err)
+		t.Fatalf("ExportTiersSVG failed: %v", err)
 	}
 
 	data, err := os.ReadFile(tmpfile.Name())
@@ -77,7 +88,7 @@ func TestExportNamesSVG_ColumnColors(t *testing.T) {
 	}
 }
 
-func TestExportNamesSVG_UserColorMap(t *testing.T) {
+func TestExportTiersSVG_FlatUserColorMap(t *testing.T) {
 	names := []string{"X", "Y"}
 	settings := Settings{
 		Width:        200,
@@ -96,9 +107,9 @@ func TestExportNamesSVG_UserColorMap(t *testing.T) {
 	}
 	defer os.Remove(tmpfile.Name())
 
-	err = ExportNamesSVG(names, tmpfile.Name(), settings)
+	err = exportFlatSVG(names, tmpfile.Name(), settings)
 	if err != nil {
-		t.Fatalf("ExportNamesSVG failed: %v", err)
+		t.Fatalf("ExportTiersSVG failed: %v", err)
 	}
 
 	data, err := os.ReadFile(tmpfile.Name())
@@ -119,3 +130,28 @@ func TestEscapeXML(t *testing.T) {
 		t.Errorf("escapeXML failed: got %q, want %q", got, expected)
 	}
 }
+
+func TestExportTiersSVG_FlatReferenceDateMetadata(t *testing.T) {
+	settings := Settings{
+		Width:        200,
+		Margin:       5,
+		ColGap:       5,
+		FontSize:     12,
+		LineHeight:   18,
+		Columns:      1,
+		FontFamily:   "Arial",
+		ColumnColors: []string{"#000"},
+		AsOfDate:     "2024-05-01T00:00:00Z",
+	}
+	path := filepath.Join(t.TempDir(), "all_names.svg")
+	if err := exportFlatSVG([]string{"X"}, path, settings); err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read SVG file: %v", err)
+	}
+	if !strings.Contains(string(data), `reference-date="2024-05-01T00:00:00Z"`) {
+		t.Errorf("SVG missing reference date metadata: %s", data)
+	}
+}
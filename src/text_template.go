@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// textTemplateData is what a TXT_TEMPLATE_FILE template receives.
+type textTemplateData struct {
+	Tiers         []TierGroup // in tier order; each has .Name and .Patrons
+	Patrons       []Patron    // every patron, in tier order
+	Total         int
+	ReferenceDate string // YYYY-MM-DD
+}
+
+// textTemplateFuncs are helpers for lists that templates can't easily build
+// on their own, such as comma-separated names without a trailing comma.
+var textTemplateFuncs = template.FuncMap{
+	"names": func(patrons []Patron) []string {
+		names := make([]string, len(patrons))
+		for i, p := range patrons {
+			names[i] = p.Name
+		}
+		return names
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func loadTextTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %v", err)
+	}
+	return template.New(filepath.Base(path)).Funcs(textTemplateFuncs).Parse(string(data))
+}
+
+// templateOutputName is the file a template writes: its own name without a
+// .tmpl or .tpl extension, so youtube.txt.tmpl writes youtube.txt.
+func templateOutputName(path string) string {
+	name := filepath.Base(path)
+	for _, ext := range []string{".tmpl", ".tpl"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// writeTemplateFiles renders each TXT_TEMPLATE_FILE into outputDir, in place
+// of the one-file-per-tier output of writeTierFiles.
+func writeTemplateFiles(outputDir string, tiers []TierGroup, settings Settings) error {
+	data := textTemplateData{Tiers: tiers}
+	for _, t := range tiers {
+		data.Patrons = append(data.Patrons, t.Patrons...)
+	}
+	data.Total = len(data.Patrons)
+	if len(settings.AsOfDate) >= len("2006-01-02") {
+		data.ReferenceDate = settings.AsOfDate[:len("2006-01-02")]
+	}
+
+	for _, path := range settings.TXTTemplateFiles {
+		tmpl, err := loadTextTemplate(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		filename := filepath.Join(outputDir, templateOutputName(path))
+		if err := executeTemplateFile(tmpl, filename, data); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Printf("Created %s from %s\n", filename, path)
+	}
+	return nil
+}
+
+func executeTemplateFile(tmpl *template.Template, filename string, data textTemplateData) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := tmpl.Execute(w, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
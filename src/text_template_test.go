@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTemplateFiles(t *testing.T) {
+	dir := t.TempDir()
+	youtube := filepath.Join(dir, "youtube.txt.tmpl")
+	markdown := filepath.Join(dir, "credits.md")
+	templates := map[string]string{
+		youtube:  `Thanks to {{join (names .Patrons) ", "}}!`,
+		markdown: "{{range .Tiers}}## {{.Name}}\n{{range .Patrons}}- {{.Name}} ({{.Tier}}, since {{.PatronageSinceDate}})\n{{end}}{{end}}{{.Total}} patrons as of {{.ReferenceDate}}\n",
+	}
+	for path, text := range templates {
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := defaultSettings()
+	settings.TXTTemplateFiles = []string{youtube, markdown}
+	settings.AsOfDate = "2024-05-01T00:00:00Z"
+	tiers := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Alice", Tier: "Gold", PatronageSinceDate: "2023-01-02"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "Bob", Tier: "Silver", PatronageSinceDate: "2024-03-04"}, {Name: "Carol", Tier: "Silver"}}},
+	}
+	if err := writeTemplateFiles(out, tiers, settings); err != nil {
+		t.Fatalf("writeTemplateFiles failed: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"youtube.txt": "Thanks to Alice, Bob, Carol!",
+		"credits.md":  "## Gold\n- Alice (Gold, since 2023-01-02)\n## Silver\n- Bob (Silver, since 2024-03-04)\n- Carol (Silver, since )\n3 patrons as of 2024-05-01\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Errorf("missing %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteTemplateFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Nope}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings := defaultSettings()
+	settings.TXTTemplateFiles = []string{path}
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("a template that parses should validate: %v", err)
+	}
+	if err := writeTemplateFiles(dir, nil, settings); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+
+	settings.TXTTemplateFiles = []string{filepath.Join(dir, "missing.tmpl")}
+	if err := settings.Validate(); err == nil {
+		t.Error("expected an error for a missing template")
+	}
+}
+
+func TestTemplateOutputName(t *testing.T) {
+	for in, want := range map[string]string{
+		"templates/youtube.txt.tmpl": "youtube.txt",
+		"list.TPL":                   "list",
+		"credits.md":                 "credits.md",
+		".tmpl":                      ".tmpl",
+	} {
+		if got := templateOutputName(in); got != want {
+			t.Errorf("templateOutputName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// TierGroup is one tier and its patrons, sorted by name.
+type TierGroup struct {
+	Name    string
+	Patrons []Patron
+	// Continued is set on the part of a tier carried over to a new page.
+	Continued bool
+}
+
+// applyTierAliases renames tiers listed in aliases (keyed by lower-case old
+// name) so renamed tiers are merged with their current name.
+func applyTierAliases(patrons []Patron, aliases map[string]string) []Patron {
+	if len(aliases) == 0 {
+		return patrons
+	}
+	for i, p := range patrons {
+		if target, ok := aliases[strings.ToLower(strings.TrimSpace(p.Tier))]; ok {
+			patrons[i].Tier = target
+		}
+	}
+	return patrons
+}
+
+// orderTiers turns the grouped patrons into a list in display order. Tiers named
+// in order come first, in that order; the rest follow by tier price, highest
+// first, and then by name. Prices in different currencies can't be compared,
+// so tiers are priced in the currency most patrons pledge in, and tiers with
+// no pledges in it come after the priced ones.
+func orderTiers(groups map[string][]Patron, order []string) []TierGroup {
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		rank[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var all []Patron
+	for _, patrons := range groups {
+		all = append(all, patrons...)
+	}
+	currency := majorityCurrency(all)
+	tiers := make([]TierGroup, 0, len(groups))
+	prices := make(map[string]int64, len(groups))
+	for name, patrons := range groups {
+		tiers = append(tiers, TierGroup{Name: name, Patrons: patrons})
+		prices[name] = tierPrice(patrons, currency)
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		ri, iRanked := rank[strings.ToLower(tiers[i].Name)]
+		rj, jRanked := rank[strings.ToLower(tiers[j].Name)]
+		switch {
+		case iRanked && jRanked:
+			return ri < rj
+		case iRanked != jRanked:
+			return iRanked
+		case prices[tiers[i].Name] != prices[tiers[j].Name]:
+			return prices[tiers[i].Name] > prices[tiers[j].Name]
+		}
+		return strings.ToLower(tiers[i].Name) < strings.ToLower(tiers[j].Name)
+	})
+	return tiers
+}
+
+// majorityCurrency is the currency of the most non-zero pledges, the first
+// by code on a tie.
+func majorityCurrency(patrons []Patron) string {
+	counts := make(map[string]int)
+	for _, p := range patrons {
+		if pledge := p.Typed().PledgeAmount; pledge.Minor > 0 {
+			counts[pledge.Currency]++
+		}
+	}
+	best := ""
+	for c, n := range counts {
+		if n > counts[best] || (n == counts[best] && c < best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// tierPrice estimates what a tier costs, in minor units of currency, from the
+// lowest non-zero pledge in that currency, since patrons can choose to pay
+// more than the tier price but not less. It is 0 when no one in the tier
+// pledges in currency.
+func tierPrice(patrons []Patron, currency string) int64 {
+	var price int64
+	for _, p := range patrons {
+		pledge := p.Typed().PledgeAmount
+		if pledge.Currency != currency || pledge.Minor <= 0 {
+			continue
+		}
+		if price == 0 || pledge.Minor < price {
+			price = pledge.Minor
+		}
+	}
+	return price
+}
+
+// visibleTiers drops the tiers named in hidden (case-insensitive).
+func visibleTiers(tiers []TierGroup, hidden []string) []TierGroup {
+	if len(hidden) == 0 {
+		return tiers
+	}
+	skip := make(map[string]bool, len(hidden))
+	for _, name := range hidden {
+		skip[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	var visible []TierGroup
+	for _, t := range tiers {
+		if !skip[strings.ToLower(t.Name)] {
+			visible = append(visible, t)
+		}
+	}
+	return visible
+}
+
+// tierNames returns every patron name in tier order.
+func tierNames(tiers []TierGroup) []string {
+	var names []string
+	for _, t := range tiers {
+		for _, p := range t.Patrons {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyTierAliases(t *testing.T) {
+	patrons := []Patron{{Name: "A", Tier: "Gold (legacy)"}, {Name: "B", Tier: "Gold"}, {Name: "C", Tier: "Silver"}}
+	patrons = applyTierAliases(patrons, map[string]string{"gold (legacy)": "Gold"})
+	groups := groupAndSortByTier(patrons)
+	if len(groups["Gold"]) != 2 || len(groups) != 2 {
+		t.Errorf("expected legacy tier merged into Gold, got %v", groups)
+	}
+}
+
+func TestOrderTiers(t *testing.T) {
+	groups := map[string][]Patron{
+		"Bronze": {{Name: "A", PledgeAmount: "3.00"}},
+		"Gold":   {{Name: "B", PledgeAmount: "25.00"}, {Name: "C", PledgeAmount: "10.00"}},
+		"Silver": {{Name: "D", PledgeAmount: "5.00"}},
+		"Backer": {{Name: "E", PledgeAmount: "5.00"}},
+	}
+	var got []string
+	for _, tier := range orderTiers(groups, nil) {
+		got = append(got, tier.Name)
+	}
+	if want := "Gold,Backer,Silver,Bronze"; strings.Join(got, ",") != want {
+		t.Errorf("price order = %v, want %s", got, want)
+	}
+
+	got = nil
+	for _, tier := range orderTiers(groups, []string{"silver", "Bronze"}) {
+		got = append(got, tier.Name)
+	}
+	if want := "Silver,Bronze,Gold,Backer"; strings.Join(got, ",") != want {
+		t.Errorf("explicit order = %v, want %s", got, want)
+	}
+}
+
+func TestOrderTiers_Currencies(t *testing.T) {
+	groups := map[string][]Patron{
+		"Yen":    {{Name: "A", PledgeAmount: "500", Currency: "JPY"}},
+		"Gold":   {{Name: "B", PledgeAmount: "50.00", Currency: "USD"}, {Name: "C", PledgeAmount: "2000", Currency: "JPY"}},
+		"Silver": {{Name: "D", PledgeAmount: "5.00", Currency: "USD"}, {Name: "E", PledgeAmount: "5.00", Currency: "USD"}},
+	}
+	var got []string
+	for _, tier := range orderTiers(groups, nil) {
+		got = append(got, tier.Name)
+	}
+	// USD is the majority currency; the yen-only tier can't be priced in it.
+	if want := "Gold,Silver,Yen"; strings.Join(got, ",") != want {
+		t.Errorf("price order = %v, want %s", got, want)
+	}
+}
+
+func TestVisibleTiers(t *testing.T) {
+	tiers := []TierGroup{{Name: "Gold"}, {Name: "Staff"}, {Name: "Silver"}}
+	visible := visibleTiers(tiers, []string{"staff"})
+	if len(visible) != 2 || visible[0].Name != "Gold" || visible[1].Name != "Silver" {
+		t.Errorf("unexpected visible tiers: %v", visible)
+	}
+}
+
+func TestExportTiersSVG_TierOrder(t *testing.T) {
+	tiers := []TierGroup{
+		{Name: "Gold", Patrons: []Patron{{Name: "Zed"}}},
+		{Name: "Silver", Patrons: []Patron{{Name: "Alice"}}},
+	}
+	settings := Settings{
+		Width:        200,
+		Margin:       5,
+		ColGap:       5,
+		FontSize:     12,
+		LineHeight:   18,
+		Columns:      1,
+		FontFamily:   "Arial",
+		ColumnColors: []string{"#000"},
+	}
+	path := filepath.Join(t.TempDir(), "all_names.svg")
+	if _, err := ExportTiersSVG(tiers, path, settings); err != nil {
+		t.Fatalf("ExportTiersSVG failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read SVG file: %v", err)
+	}
+	content := string(data)
+	if strings.Index(content, "Zed") > strings.Index(content, "Alice") {
+		t.Error("expected Gold tier names before Silver tier names")
+	}
+}